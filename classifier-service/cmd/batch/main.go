@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"runtime"
+	"strconv"
+	"time"
+
+	"github.com/ivar-mahhonin/financial-service-delivery-classifier/classifier/pkg/server"
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils"
+)
+
+// Exit codes of the batch CLI.
+const (
+	EXIT_OK      = 0
+	EXIT_FAILURE = 1
+	EXIT_USAGE   = 2
+
+	// Number of tickets between progress messages.
+	PROGRESS_EVERY = 10000
+)
+
+func main() {
+	if errLoadinEnv := util.LoadEnvFile(); errLoadinEnv != nil {
+		log.Print("No .env file found, using flags and process environment")
+	}
+	os.Exit(run(os.Args[1:]))
+}
+
+// Classifies tickets of a dataset file and writes results as JSON lines.
+func run(args []string) int {
+	fs := flag.NewFlagSet("batch", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	format := fs.String("format", "", "input format: json for Elasticsearch exports, jsonl or csv, detected from the file extension when empty")
+	outputFileDir := fs.String("output", "", "file to write JSON lines of results to, stdout when empty")
+	workers := fs.Int("workers", runtime.NumCPU(), "number of tickets classified in parallel")
+	idField := fs.String("id-field", "", "ticket field with the id, _id or id when empty")
+	modelFileDir := fs.String("model", util.GetEnvVariable("MODEL_FILE_DIR"), "model file, overrides MODEL_FILE_DIR")
+	registryDir := fs.String("registry", util.GetEnvVariable("MODEL_REGISTRY_DIR"), "model registry directory, takes precedence over --model, overrides MODEL_REGISTRY_DIR")
+	version := fs.String("version", util.VERSION_PRODUCTION, "version of the registry to classify with: an id, production or latest")
+	policyFileDir := fs.String("policy", util.GetEnvVariable("ABSTENTION_POLICY_FILE"), "JSON file with abstention policy, overrides ABSTENTION_POLICY_FILE")
+	minProbability := fs.Float64("min-probability", envFloat("MIN_PROBABILITY"), "mark tickets for review when probability of the most likely class is below the value, overrides MIN_PROBABILITY")
+	minMargin := fs.Float64("min-margin", envFloat("MIN_MARGIN"), "mark tickets for review when the two most likely classes differ less than the value, overrides MIN_MARGIN")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: batch [flags] <tickets file>")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return EXIT_OK
+		}
+		return EXIT_USAGE
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	inputFileDir := fs.Arg(0)
+
+	if *format == "" {
+		detected, err := util.DetectFormat(inputFileDir)
+		if err != nil {
+			log.Print(err)
+			return EXIT_USAGE
+		}
+		*format = detected
+	}
+	reader, err := util.NewDatasetReader(*format)
+	if err != nil {
+		log.Print(err)
+		return EXIT_USAGE
+	}
+
+	resolve := func() (string, error) { return *modelFileDir, nil }
+	if *registryDir != "" {
+		resolve = func() (string, error) { return util.NewModelRegistry(*registryDir).Resolve(*version) }
+	} else if *modelFileDir == "" {
+		log.Print("--model or --registry flag or their environment variables are empty")
+		return EXIT_USAGE
+	}
+
+	// Like the server, MIN_PROBABILITY and MIN_MARGIN override values of the policy file,
+	// and thresholds passed explicitly override both.
+	policy, err := util.LoadAbstentionPolicy(*policyFileDir)
+	if err != nil {
+		log.Print("Can not read abstention policy: ", err)
+		return EXIT_FAILURE
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "min-probability":
+			policy.MinProbability = *minProbability
+		case "min-margin":
+			policy.MinMargin = *minMargin
+		}
+	})
+
+	srv, err := server.NewServerFromSource(server.BundleSource{Resolve: resolve}, policy, "")
+	if err != nil {
+		log.Print("Can not load model: ", err)
+		return EXIT_FAILURE
+	}
+
+	input, err := os.Open(inputFileDir)
+	if err != nil {
+		log.Print("Can not open tickets: ", err)
+		return EXIT_FAILURE
+	}
+	defer input.Close()
+
+	var output io.Writer = os.Stdout
+	if *outputFileDir != "" {
+		file, err := os.Create(*outputFileDir)
+		if err != nil {
+			log.Print("Can not create output: ", err)
+			return EXIT_FAILURE
+		}
+		defer file.Close()
+		output = file
+	}
+	buffered := bufio.NewWriter(output)
+	encoder := json.NewEncoder(buffered)
+
+	started := time.Now()
+	tickets, failed := 0, 0
+	err = srv.ClassifyBatch(func(fn func(models.Record) error) error {
+		return reader.Read(bufio.NewReader(input), fn)
+	}, server.BatchOptions{Workers: *workers, IDField: *idField}, func(result server.BatchResult) error {
+		tickets++
+		if result.Error != "" {
+			failed++
+		}
+		if tickets%PROGRESS_EVERY == 0 {
+			log.Printf("Classified %d tickets", tickets)
+		}
+		return encoder.Encode(result)
+	})
+	if err == nil {
+		err = buffered.Flush()
+	}
+	if err != nil {
+		log.Printf("Batch failed after %d tickets: %v", tickets, err)
+		return EXIT_FAILURE
+	}
+
+	elapsed := time.Since(started)
+	log.Printf("Classified %d tickets in %s with %d workers, %.0f tickets/s, %d without text",
+		tickets, elapsed.Round(time.Millisecond), *workers, float64(tickets)/elapsed.Seconds(), failed)
+	return EXIT_OK
+}
+
+func envFloat(key string) float64 {
+	value := util.GetEnvVariable(key)
+	if value == "" {
+		return 0
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("%s is not a number, using 0", key)
+		return 0
+	}
+	return f
+}
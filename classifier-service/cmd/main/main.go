@@ -0,0 +1,112 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/ivar-mahhonin/financial-service-delivery-classifier/classifier/pkg/server"
+	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils"
+)
+
+const (
+	DEFAULT_PORT            = "8080"
+	DEFAULT_RELOAD_INTERVAL = 30 * time.Second
+
+	// Timeouts of client connections, long enough to upload and classify the largest batch.
+	READ_HEADER_TIMEOUT = 10 * time.Second
+	READ_TIMEOUT        = time.Minute
+	WRITE_TIMEOUT       = 2 * time.Minute
+	IDLE_TIMEOUT        = 2 * time.Minute
+)
+
+func main() {
+	errLoadinEnv := util.LoadEnvFile()
+
+	if errLoadinEnv != nil {
+		log.Print("No .env file found, using process environment")
+	}
+
+	port := util.GetEnvVariable("PORT")
+	if port == "" {
+		port = DEFAULT_PORT
+	}
+
+	policy, err := abstentionPolicy()
+	if err != nil {
+		log.Fatal("Can not read abstention policy: ", err)
+	}
+	log.Printf("Abstention policy: min probability %.2f, min margin %.2f, %d class thresholds",
+		policy.MinProbability, policy.MinMargin, len(policy.ClassThresholds))
+
+	interval, err := reloadInterval()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	adminToken := util.GetEnvVariable("ADMIN_TOKEN")
+	srv, err := server.NewServerFromSource(server.BundleSource{Resolve: modelFile}, policy, adminToken)
+	if err != nil {
+		log.Fatal("Can not load model: ", err)
+	}
+	if adminToken == "" {
+		log.Print("ADMIN_TOKEN is not set, /admin/reload is disabled")
+	}
+	if interval > 0 {
+		log.Printf("Checking for a new model every %s", interval)
+		go srv.Watch(context.Background(), interval)
+	}
+	addr := fmt.Sprintf(":%s", port)
+
+	log.Printf("Listening on %s", addr)
+	httpServer := &http.Server{
+		Addr:              addr,
+		Handler:           srv.Handler(),
+		ReadHeaderTimeout: READ_HEADER_TIMEOUT,
+		ReadTimeout:       READ_TIMEOUT,
+		WriteTimeout:      WRITE_TIMEOUT,
+		IdleTimeout:       IDLE_TIMEOUT,
+	}
+	if err := httpServer.ListenAndServe(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Returns the production version of the MODEL_REGISTRY_DIR registry when it is set, MODEL_FILE_DIR otherwise.
+func modelFile() (string, error) {
+	if registryDir := util.GetEnvVariable("MODEL_REGISTRY_DIR"); registryDir != "" {
+		modelFileDir, err := util.NewModelRegistry(registryDir).Resolve(util.VERSION_PRODUCTION)
+		if err != nil {
+			return "", fmt.Errorf("can not resolve production model of registry %s: %w", registryDir, err)
+		}
+		return modelFileDir, nil
+	}
+
+	modelFileDir := util.GetEnvVariable("MODEL_FILE_DIR")
+	if modelFileDir == "" {
+		return "", errors.New("MODEL_FILE_DIR or MODEL_REGISTRY_DIR is empty")
+	}
+	return modelFileDir, nil
+}
+
+// Reads how often to check for a new model from MODEL_RELOAD_INTERVAL, 0 disables the checks.
+func reloadInterval() (time.Duration, error) {
+	value := util.GetEnvVariable("MODEL_RELOAD_INTERVAL")
+	if value == "" {
+		return DEFAULT_RELOAD_INTERVAL, nil
+	}
+	interval, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("MODEL_RELOAD_INTERVAL is not a duration: %w", err)
+	}
+	return interval, nil
+}
+
+// Reads the abstention policy from ABSTENTION_POLICY_FILE when it is set,
+// MIN_PROBABILITY and MIN_MARGIN override the values of the file.
+func abstentionPolicy() (util.AbstentionPolicy, error) {
+	return util.LoadAbstentionPolicy(util.GetEnvVariable("ABSTENTION_POLICY_FILE"))
+}
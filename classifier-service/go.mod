@@ -3,8 +3,14 @@ module github.com/ivar-mahhonin/financial-service-delivery-classifier/classifier
 go 1.18
 
 require (
-	github.com/aaaton/golem/v4 v4.0.1
-	github.com/aaaton/golem/v4/dicts/en v1.0.1
-	github.com/joho/godotenv v1.4.0
+	github.com/ivar-mahhonin/food-delivery-classifier/trainer-service v0.0.0-00010101000000-000000000000
 	github.com/navossoc/bayesian v0.0.0-20171203014413-18fc5ea11e24
 )
+
+require (
+	github.com/aaaton/golem/v4 v4.0.1 // indirect
+	github.com/aaaton/golem/v4/dicts/en v1.0.1 // indirect
+	github.com/joho/godotenv v1.4.0 // indirect
+)
+
+replace github.com/ivar-mahhonin/food-delivery-classifier/trainer-service => ../trainer-service
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils"
+)
+
+const (
+	// Tickets of a single batch request. Results are sent once the whole request is read,
+	// larger backfills are classified with the batch CLI.
+	MAX_BATCH_TICKETS = 10000
+	// Size of the body of a single batch request in bytes.
+	MAX_BATCH_BYTES = 32 << 20
+
+	CONTENT_TYPE_NDJSON = "application/x-ndjson"
+)
+
+var (
+	ErrModelNotLoaded = errors.New("model is not loaded")
+	errBatchTooLarge  = fmt.Errorf("batch has more than %d tickets, classify larger batches with the batch CLI", MAX_BATCH_TICKETS)
+)
+
+// Classification of a single ticket of a batch.
+type BatchResult struct {
+	// The ticket id, or its position in the batch starting from 1 when the ticket has none.
+	ID            string             `json:"id"`
+	Product       string             `json:"product,omitempty"`
+	Candidate     string             `json:"candidate,omitempty"`
+	NeedsReview   bool               `json:"needs_review"`
+	Reason        string             `json:"reason,omitempty"`
+	Probabilities map[string]float64 `json:"probabilities,omitempty"`
+	// Classes from the candidate product to its sub-product, set by hierarchical models.
+	Path []string `json:"path,omitempty"`
+	// Fingerprint of the model the ticket was classified with.
+	Model string `json:"model,omitempty"`
+	// Set when the ticket could not be classified.
+	Error string `json:"error,omitempty"`
+}
+
+type BatchOptions struct {
+	// Number of tickets classified in parallel, the number of CPUs when not positive.
+	Workers int
+	// Ticket field with the id, _id or id when empty.
+	IDField string
+}
+
+type batchJob struct {
+	position int
+	record   models.Record
+	result   chan BatchResult
+}
+
+// Classifies tickets passed by read to its callback with a pool of workers and calls emit with
+// results in the order of tickets. The whole batch is classified by the model served when it
+// starts, even when another model is loaded meanwhile.
+func (s *Server) ClassifyBatch(read func(fn func(models.Record) error) error, options BatchOptions, emit func(BatchResult) error) error {
+	model := s.Model()
+	if model == nil {
+		return ErrModelNotLoaded
+	}
+	workers := options.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+
+	jobs := make(chan batchJob)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for job := range jobs {
+				job.result <- s.classifyTicket(model, job.position, job.record, options.IDField)
+			}
+		}()
+	}
+
+	// Results are emitted in the order jobs were queued, the queue bounds tickets in flight.
+	queue := make(chan chan BatchResult, workers*4)
+	stop := make(chan struct{})
+	var readErr error
+	go func() {
+		defer close(queue)
+		defer close(jobs)
+		position := 0
+		readErr = read(func(record models.Record) error {
+			position++
+			result := make(chan BatchResult, 1)
+			select {
+			case queue <- result:
+			case <-stop:
+				return errors.New("batch stopped")
+			}
+			jobs <- batchJob{position: position, record: record, result: result}
+			return nil
+		})
+	}()
+
+	var emitErr error
+	for result := range queue {
+		r := <-result
+		if emitErr != nil {
+			continue
+		}
+		if emitErr = emit(r); emitErr != nil {
+			close(stop)
+		}
+	}
+	if emitErr != nil {
+		return emitErr
+	}
+	return readErr
+}
+
+func (s *Server) classifyTicket(model *Model, position int, record models.Record, idField string) BatchResult {
+	result := BatchResult{ID: ticketID(record, idField), Model: model.Info.Fingerprint}
+	if result.ID == "" {
+		result.ID = strconv.Itoa(position)
+	}
+
+	text := util.MappedText(model.mapping, record)
+	if text == "" {
+		result.Error = fmt.Sprintf("%s is required", strings.Join(util.TextFields(model.mapping), " or "))
+		return result
+	}
+
+	response := s.classify(model, text)
+	result.Product = response.Product
+	result.Candidate = response.Candidate
+	result.NeedsReview = response.NeedsReview
+	result.Reason = response.Reason
+	result.Probabilities = response.Probabilities
+	if response.Path != nil {
+		result.Path = response.Path.Path
+	}
+	return result
+}
+
+func ticketID(record models.Record, idField string) string {
+	if idField != "" {
+		return record[idField]
+	}
+	if id := record[util.RECORD_ID_FIELD]; id != "" {
+		return id
+	}
+	return record["id"]
+}
+
+// Classifies a JSON array of tickets, or JSON lines of tickets when sent as application/x-ndjson,
+// and responds with results in the same format and order.
+func (s *Server) handleClassifyBatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	body := &countingReader{r: http.MaxBytesReader(w, r.Body, MAX_BATCH_BYTES)}
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	ndjson := mediaType == CONTENT_TYPE_NDJSON
+	read := func(fn func(models.Record) error) error {
+		if ndjson {
+			reader, _ := util.NewDatasetReader(util.FORMAT_JSONL)
+			return reader.Read(body, fn)
+		}
+		return readJSONArray(body, fn)
+	}
+
+	// HTTP/1 clients may not read the response before the request is sent, so results are
+	// collected and written once the whole request is read.
+	var results []BatchResult
+	err := s.ClassifyBatch(func(fn func(models.Record) error) error {
+		tickets := 0
+		return read(func(record models.Record) error {
+			if tickets++; tickets > MAX_BATCH_TICKETS {
+				return errBatchTooLarge
+			}
+			return fn(record)
+		})
+	}, BatchOptions{}, func(result BatchResult) error {
+		results = append(results, result)
+		return nil
+	})
+	switch {
+	case errors.Is(err, ErrModelNotLoaded):
+		writeError(w, http.StatusServiceUnavailable, err.Error())
+		return
+	case errors.Is(err, errBatchTooLarge):
+		writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+		return
+	case err != nil && body.n >= MAX_BATCH_BYTES:
+		writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body is larger than %d bytes, classify larger batches with the batch CLI", MAX_BATCH_BYTES))
+		return
+	case err != nil:
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("request body is not a valid batch of tickets: %v", err))
+		return
+	}
+
+	if !ndjson {
+		if results == nil {
+			results = []BatchResult{}
+		}
+		writeJSON(w, http.StatusOK, results)
+		return
+	}
+	w.Header().Set("Content-Type", CONTENT_TYPE_NDJSON)
+	w.WriteHeader(http.StatusOK)
+	if err := writeNDJSON(w, results); err != nil {
+		log.Print("Can not write response: ", err)
+	}
+}
+
+// Counts bytes read from the request body. Going over the limit of http.MaxBytesReader
+// is told by the count, the error it returns has no type to check.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Writes results as JSON lines.
+func writeNDJSON(w io.Writer, results []BatchResult) error {
+	encoder := json.NewEncoder(w)
+	for _, result := range results {
+		if err := encoder.Encode(result); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Decodes a JSON array of tickets one at a time.
+func readJSONArray(r io.Reader, fn func(models.Record) error) error {
+	decoder := json.NewDecoder(r)
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '[' {
+		return errors.New("array of tickets expected")
+	}
+
+	for decoder.More() {
+		var ticket map[string]interface{}
+		if err := decoder.Decode(&ticket); err != nil {
+			return err
+		}
+		if err := fn(util.RecordFromJSON(ticket)); err != nil {
+			return err
+		}
+	}
+	_, err = decoder.Token()
+	return err
+}
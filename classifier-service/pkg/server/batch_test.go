@@ -0,0 +1,197 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils"
+)
+
+func recordsOf(records ...models.Record) func(fn func(models.Record) error) error {
+	return func(fn func(models.Record) error) error {
+		for _, record := range records {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
+
+func TestClassifyBatchKeepsOrder(t *testing.T) {
+	var records []models.Record
+	for i := 0; i < 200; i++ {
+		issue := "escrow payment"
+		if i%2 == 1 {
+			issue = "card fee"
+		}
+		records = append(records, models.Record{"_id": fmt.Sprintf("t%d", i), "issue": issue})
+	}
+
+	var results []BatchResult
+	err := newTestServer().ClassifyBatch(recordsOf(records...), BatchOptions{Workers: 8}, func(result BatchResult) error {
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(results) != len(records) {
+		t.Fatalf("Expected %d results, got %d", len(records), len(results))
+	}
+	for i, result := range results {
+		expected := "Mortgage"
+		if i%2 == 1 {
+			expected = "Credit card"
+		}
+		if result.ID != records[i]["_id"] || result.Product != expected || len(result.Probabilities) != 2 {
+			t.Errorf("Unexpected result %d: %+v", i, result)
+		}
+	}
+}
+
+func TestClassifyBatchTicketIDs(t *testing.T) {
+	records := []models.Record{
+		{"_id": "a", "issue": "escrow"},
+		{"id": "b", "issue": "escrow"},
+		{"ticket": "c", "issue": "escrow"},
+		{"issue": "escrow"},
+		{"_id": "e"},
+	}
+	var results []BatchResult
+	err := newTestServer().ClassifyBatch(recordsOf(records...), BatchOptions{Workers: 2}, func(result BatchResult) error {
+		results = append(results, result)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ids := []string{}
+	for _, result := range results {
+		ids = append(ids, result.ID)
+	}
+	if strings.Join(ids, ",") != "a,b,3,4,e" {
+		t.Errorf("Test case failed: got %v, want %v", ids, []string{"a", "b", "3", "4", "e"})
+	}
+	if results[4].Error == "" || results[4].Product != "" {
+		t.Errorf("Expected ticket without text to fail, got %+v", results[4])
+	}
+
+	results = nil
+	newTestServer().ClassifyBatch(recordsOf(records[2]), BatchOptions{IDField: "ticket"}, func(result BatchResult) error {
+		results = append(results, result)
+		return nil
+	})
+	if results[0].ID != "c" {
+		t.Errorf("Expected id from the id field, got %s", results[0].ID)
+	}
+}
+
+func TestClassifyBatchStopsOnEmitError(t *testing.T) {
+	records := make([]models.Record, 100)
+	for i := range records {
+		records[i] = models.Record{"issue": "escrow"}
+	}
+
+	errWriting := errors.New("disk full")
+	emitted := 0
+	err := newTestServer().ClassifyBatch(recordsOf(records...), BatchOptions{Workers: 4}, func(result BatchResult) error {
+		emitted++
+		return errWriting
+	})
+	if !errors.Is(err, errWriting) || emitted != 1 {
+		t.Errorf("Expected batch to stop after the first emit error, got %d results and %v", emitted, err)
+	}
+}
+
+func TestClassifyBatchWithoutModel(t *testing.T) {
+	srv := NewServer(nil, util.DefaultFieldMapping(), util.AbstentionPolicy{})
+	err := srv.ClassifyBatch(recordsOf(), BatchOptions{}, func(BatchResult) error { return nil })
+	if !errors.Is(err, ErrModelNotLoaded) {
+		t.Errorf("Expected ErrModelNotLoaded, got %v", err)
+	}
+}
+
+func TestClassifyBatchEndpointArray(t *testing.T) {
+	body := []byte(`[{"_id": "1", "issue": "Escrow payment"}, {"_id": "2", "issue": "Card fee"}]`)
+	rec := httptest.NewRecorder()
+	newTestServer().Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/classify/batch", bytes.NewReader(body)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+	var results []BatchResult
+	if err := json.NewDecoder(rec.Body).Decode(&results); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if len(results) != 2 || results[0].Product != "Mortgage" || results[1].ID != "2" || results[1].Product != "Credit card" {
+		t.Errorf("Unexpected results: %+v", results)
+	}
+}
+
+func TestClassifyBatchEndpointNDJSON(t *testing.T) {
+	body := "{\"_id\": \"1\", \"issue\": \"Escrow payment\"}\n{\"_id\": \"2\", \"issue\": \"Card fee\"}\n"
+	req := httptest.NewRequest(http.MethodPost, "/v1/classify/batch", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	rec := httptest.NewRecorder()
+	newTestServer().Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != CONTENT_TYPE_NDJSON {
+		t.Fatalf("Expected NDJSON response, got %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	var ids []string
+	scanner := bufio.NewScanner(rec.Body)
+	for scanner.Scan() {
+		var result BatchResult
+		if err := json.Unmarshal(scanner.Bytes(), &result); err != nil {
+			t.Fatalf("Error decoding result line: %v", err)
+		}
+		ids = append(ids, result.ID)
+	}
+	if strings.Join(ids, ",") != "1,2" {
+		t.Errorf("Test case failed: got %v, want %v", ids, []string{"1", "2"})
+	}
+}
+
+func TestClassifyBatchEndpointInvalid(t *testing.T) {
+	for _, body := range []string{`{"issue": "escrow"}`, `[{"issue": "escrow"}, `, `not json`} {
+		rec := httptest.NewRecorder()
+		newTestServer().Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/classify/batch", strings.NewReader(body)))
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected status %d for %q, got %d", http.StatusBadRequest, body, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	newTestServer().Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/classify/batch", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestClassifyBatchEndpointTooLarge(t *testing.T) {
+	body := "[" + strings.Repeat(`{"issue": "escrow"},`, MAX_BATCH_TICKETS) + `{"issue": "escrow"}]`
+	rec := httptest.NewRecorder()
+	newTestServer().Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/classify/batch", strings.NewReader(body)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+}
+
+func TestClassifyBatchEndpointBodyTooLarge(t *testing.T) {
+	body := `[{"issue": "` + strings.Repeat("escrow ", MAX_BATCH_BYTES/7) + `"}]`
+	rec := httptest.NewRecorder()
+	newTestServer().Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/classify/batch", strings.NewReader(body)))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+}
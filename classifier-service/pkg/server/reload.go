@@ -0,0 +1,246 @@
+package server
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils"
+)
+
+const (
+	// Text classified by a freshly loaded model before it is served.
+	SMOKE_TEXT = "smoke test of the loaded model"
+)
+
+// Returned by Reload when the server was not created from a model source.
+var ErrReloadDisabled = errors.New("model reload is not enabled")
+
+// A model loaded and validated for serving.
+type Model struct {
+	// Ranks products, root classes of hierarchical models.
+	predictor *util.Predictor
+	// Set for hierarchical models to predict the path from the product to its sub-product.
+	hierarchy *util.HierarchicalPredictor
+	// Mapping of ticket fields onto the text the model was trained with.
+	mapping models.FieldMapping
+	Info    ModelInfo
+}
+
+type ModelInfo struct {
+	Path        string    `json:"path,omitempty"`
+	Kind        string    `json:"kind,omitempty"`
+	Fingerprint string    `json:"fingerprint,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+	Classes     int       `json:"classes"`
+	LoadedAt    time.Time `json:"loaded_at"`
+}
+
+// Builds a servable model from the bundle read from the path. Hierarchical models rank
+// their root classes as products and predict the path to the sub-product as well.
+func NewModel(bundle *util.ModelBundle, path string) (*Model, error) {
+	model := &Model{
+		mapping: bundle.Manifest.Mapping,
+		Info: ModelInfo{
+			Path:        path,
+			Kind:        bundle.Manifest.Kind,
+			Fingerprint: bundle.Manifest.Fingerprint.Fingerprint,
+			CreatedAt:   bundle.Manifest.CreatedAt,
+			Classes:     len(bundle.Manifest.Classes),
+			LoadedAt:    time.Now().UTC(),
+		},
+	}
+	var err error
+	switch bundle.Manifest.Kind {
+	case util.MODEL_KIND_FLAT:
+		model.predictor, err = bundle.Predictor()
+	case util.MODEL_KIND_HIERARCHICAL:
+		if model.hierarchy, err = bundle.HierarchicalPredictor(); err == nil {
+			model.predictor = model.hierarchy.Root()
+		}
+	default:
+		err = fmt.Errorf("%s models can not be served", bundle.Manifest.Kind)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("model %s: %w", path, err)
+	}
+	return model, nil
+}
+
+// Where the served model is published.
+type ModelSource interface {
+	// Returns a value that changes whenever another model is published.
+	Revision() (string, error)
+	Load() (*Model, error)
+}
+
+// A model bundle file. The path is resolved on every check, so promoting another
+// version of a registry is noticed as well as replacing the file.
+type BundleSource struct {
+	Resolve func() (string, error)
+}
+
+func (b BundleSource) Revision() (string, error) {
+	path, err := b.Resolve()
+	if err != nil {
+		return "", err
+	}
+	model, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %d %d", path, model.Size(), model.ModTime().UnixNano()), nil
+}
+
+func (b BundleSource) Load() (*Model, error) {
+	path, err := b.Resolve()
+	if err != nil {
+		return nil, err
+	}
+	bundle, err := util.ReadModelBundle(path)
+	if err != nil {
+		return nil, err
+	}
+	return NewModel(bundle, path)
+}
+
+type reloadState struct {
+	// Serializes reloads, requests are served from the current model meanwhile.
+	mu         sync.Mutex
+	source     ModelSource
+	adminToken string
+	// Revisions of the source that were served and that failed to load.
+	revision string
+	failed   string
+}
+
+type ReloadResult struct {
+	// False when the source had no new model.
+	Reloaded bool      `json:"reloaded"`
+	Model    ModelInfo `json:"model"`
+}
+
+// Serves the model of the source, reloaded with Watch or POST /admin/reload. The admin
+// endpoint requires the token as a bearer token and is not served without one.
+func NewServerFromSource(source ModelSource, policy util.AbstentionPolicy, adminToken string) (*Server, error) {
+	s := &Server{policy: policy}
+	s.reload.source = source
+	s.reload.adminToken = adminToken
+	if _, err := s.Reload(true); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Loads the model of the source, checks it with a smoke prediction and swaps it in, so requests
+// in flight finish on the previous model. Unless forced, nothing is loaded when the source
+// has the same model as on the last reload. The previous model is kept when loading fails.
+func (s *Server) Reload(force bool) (ReloadResult, error) {
+	state := &s.reload
+	if state.source == nil {
+		return ReloadResult{}, ErrReloadDisabled
+	}
+	state.mu.Lock()
+	defer state.mu.Unlock()
+
+	var result ReloadResult
+	if current := s.Model(); current != nil {
+		result.Model = current.Info
+	}
+
+	revision, err := state.source.Revision()
+	if err != nil {
+		return result, err
+	}
+	if !force && (revision == state.revision || revision == state.failed) {
+		return result, nil
+	}
+
+	model, err := state.source.Load()
+	if err == nil {
+		err = smokePrediction(model)
+	}
+	if err != nil {
+		state.failed = revision
+		return result, err
+	}
+
+	s.setModel(model)
+	state.revision, state.failed = revision, ""
+	log.Printf("Loaded model %s with %d classes from %s, created at %s", model.Info.Fingerprint, model.Info.Classes, model.Info.Path, model.Info.CreatedAt)
+	return ReloadResult{Reloaded: true, Model: model.Info}, nil
+}
+
+// Reloads the model when the source publishes a new one, checking every interval until the context is done.
+func (s *Server) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.Reload(false); err != nil {
+				log.Print("Can not reload model, serving the previous one: ", err)
+			}
+		}
+	}
+}
+
+// Classifies SMOKE_TEXT, so a model that loads but can not rank its classes is never served.
+func smokePrediction(model *Model) error {
+	ranking := model.predictor.Predict(SMOKE_TEXT)
+	if len(ranking) == 0 || len(ranking) != model.Info.Classes {
+		return fmt.Errorf("smoke prediction ranked %d classes, the model has %d", len(ranking), model.Info.Classes)
+	}
+
+	sum := 0.0
+	for _, p := range ranking {
+		if math.IsNaN(p.Probability) || p.Probability < 0 || p.Probability > 1 {
+			return fmt.Errorf("smoke prediction has invalid probability %f of %s", p.Probability, p.Class)
+		}
+		sum += p.Probability
+	}
+	if math.Abs(sum-1) > 1e-6 {
+		return fmt.Errorf("smoke prediction probabilities sum up to %f", sum)
+	}
+	return nil
+}
+
+func (s *Server) handleReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	// The token is accepted only with the Bearer scheme, a bare token is rejected.
+	token := s.reload.adminToken
+	header := r.Header.Get("Authorization")
+	if token == "" || !strings.HasPrefix(header, "Bearer ") ||
+		subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(header, "Bearer ")), []byte(token)) != 1 {
+		writeError(w, http.StatusUnauthorized, "admin token is required")
+		return
+	}
+
+	result, err := s.Reload(true)
+	if errors.Is(err, ErrReloadDisabled) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	if err != nil {
+		log.Print("Can not reload model, serving the previous one: ", err)
+		writeError(w, http.StatusInternalServerError, fmt.Sprintf("can not reload model, serving the previous one: %v", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
@@ -0,0 +1,296 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils"
+	"github.com/navossoc/bayesian"
+)
+
+type testSource struct {
+	mu       sync.Mutex
+	revision string
+	model    *Model
+	err      error
+	loads    int
+}
+
+func (s *testSource) Revision() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.revision, nil
+}
+
+func (s *testSource) Load() (*Model, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.loads++
+	return s.model, s.err
+}
+
+func (s *testSource) publish(revision string, model *Model, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revision, s.model, s.err = revision, model, err
+}
+
+func newTestModel(fingerprint string, classes ...bayesian.Class) *Model {
+	classifier := bayesian.NewClassifier(classes...)
+	for _, class := range classes {
+		classifier.Learn([]string{string(class)}, class)
+	}
+	predictor := util.NewPredictor(classifier, map[string]struct{}{}, newTestTokenizer())
+	return &Model{predictor: predictor, mapping: util.DefaultFieldMapping(), Info: ModelInfo{Fingerprint: fingerprint, Classes: len(classes)}}
+}
+
+func TestReloadSwapsModel(t *testing.T) {
+	source := &testSource{revision: "1", model: newTestModel("first", "mortgage", "card")}
+	srv, err := NewServerFromSource(source, util.AbstentionPolicy{}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	source.publish("2", newTestModel("second", "mortgage", "card", "loan"), nil)
+	result, err := srv.Reload(false)
+	if err != nil || !result.Reloaded || result.Model.Fingerprint != "second" {
+		t.Fatalf("Expected second model to be loaded, got %+v, %v", result, err)
+	}
+	if srv.Model().Info.Fingerprint != "second" {
+		t.Errorf("Expected second model to be served, got %+v", srv.Model().Info)
+	}
+
+	result, err = srv.Reload(false)
+	if err != nil || result.Reloaded || source.loads != 2 {
+		t.Errorf("Expected unchanged source not to be loaded again, got %+v after %d loads, %v", result, source.loads, err)
+	}
+}
+
+func TestReloadKeepsModelOnError(t *testing.T) {
+	source := &testSource{revision: "1", model: newTestModel("first", "mortgage", "card")}
+	srv, err := NewServerFromSource(source, util.AbstentionPolicy{}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	errBroken := errors.New("broken model")
+	source.publish("2", nil, errBroken)
+	if _, err := srv.Reload(false); !errors.Is(err, errBroken) {
+		t.Errorf("Expected load error, got %v", err)
+	}
+	if srv.Model().Info.Fingerprint != "first" {
+		t.Errorf("Expected first model to be kept, got %+v", srv.Model().Info)
+	}
+
+	if _, err := srv.Reload(false); err != nil || source.loads != 2 {
+		t.Errorf("Expected failed revision not to be loaded again, got %d loads, %v", source.loads, err)
+	}
+}
+
+func TestReloadRejectsFailedSmokePrediction(t *testing.T) {
+	source := &testSource{revision: "1", model: newTestModel("first", "mortgage", "card")}
+	srv, err := NewServerFromSource(source, util.AbstentionPolicy{}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	broken := newTestModel("second", "mortgage", "card")
+	broken.Info.Classes = 3
+	source.publish("2", broken, nil)
+	if _, err := srv.Reload(false); err == nil {
+		t.Errorf("Expected smoke prediction to fail")
+	}
+	if srv.Model().Info.Fingerprint != "first" {
+		t.Errorf("Expected first model to be kept, got %+v", srv.Model().Info)
+	}
+}
+
+func TestNewServerFromSourceWithoutModel(t *testing.T) {
+	source := &testSource{revision: "1", err: errors.New("missing")}
+	if _, err := NewServerFromSource(source, util.AbstentionPolicy{}, ""); err == nil {
+		t.Errorf("Expected error creating server without a model")
+	}
+}
+
+func TestAdminReload(t *testing.T) {
+	source := &testSource{revision: "1", model: newTestModel("first", "mortgage", "card")}
+	srv, err := NewServerFromSource(source, util.AbstentionPolicy{}, "secret")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	source.publish("2", newTestModel("second", "mortgage", "card"), nil)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d without token, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+	req.Header.Set("Authorization", "secret")
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected status %d for token without the Bearer scheme, got %d", http.StatusUnauthorized, rec.Code)
+	}
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/reload", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, req)
+	var result ReloadResult
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if rec.Code != http.StatusOK || !result.Reloaded || result.Model.Fingerprint != "second" {
+		t.Errorf("Expected second model to be loaded, got %d %+v", rec.Code, result)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/reload", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestAdminReloadDisabled(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestServer().Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d, got %d", http.StatusNotFound, rec.Code)
+	}
+}
+
+func TestAdminReloadWithoutToken(t *testing.T) {
+	source := &testSource{revision: "1", model: newTestModel("first", "mortgage", "card")}
+	srv, err := NewServerFromSource(source, util.AbstentionPolicy{}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	source.publish("2", newTestModel("second", "mortgage", "card"), nil)
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/reload", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("Expected status %d without admin token, got %d", http.StatusNotFound, rec.Code)
+	}
+	if srv.Model().Info.Fingerprint != "first" {
+		t.Errorf("Expected first model to be kept, got %s", srv.Model().Info.Fingerprint)
+	}
+}
+
+func TestWatchReloadsModel(t *testing.T) {
+	source := &testSource{revision: "1", model: newTestModel("first", "mortgage", "card")}
+	srv, err := NewServerFromSource(source, util.AbstentionPolicy{}, "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go srv.Watch(ctx, 5*time.Millisecond)
+
+	// Requests are served while the model is swapped underneath them.
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				rec := httptest.NewRecorder()
+				srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/classify", bytes.NewReader([]byte(`{"issue": "mortgage"}`))))
+				if rec.Code != http.StatusOK {
+					t.Errorf("Expected status %d during reload, got %d", http.StatusOK, rec.Code)
+				}
+			}
+		}()
+	}
+	source.publish("2", newTestModel("second", "mortgage", "card"), nil)
+	wg.Wait()
+
+	deadline := time.Now().Add(time.Second)
+	for srv.Model().Info.Fingerprint != "second" && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if srv.Model().Info.Fingerprint != "second" {
+		t.Errorf("Expected watched model to be reloaded, got %+v", srv.Model().Info)
+	}
+}
+
+func TestBundleSource(t *testing.T) {
+	classifier := bayesian.NewClassifier(bayesian.Class("Mortgage"), bayesian.Class("Credit card"))
+	classifier.Learn([]string{"mortgage"}, bayesian.Class("Mortgage"))
+	classifier.Learn([]string{"card"}, bayesian.Class("Credit card"))
+	bundle := &util.ModelBundle{Manifest: util.ModelManifest{Mapping: util.DefaultFieldMapping()}, Classifier: classifier}
+	if err := util.WriteModelBundle("test_dir/model.zip", bundle); err != nil {
+		t.Fatalf("Error writing model bundle: %v", err)
+	}
+	defer os.RemoveAll("test_dir")
+
+	source := BundleSource{Resolve: func() (string, error) { return "test_dir/model.zip", nil }}
+	first, err := source.Revision()
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	model, err := source.Load()
+	if err != nil {
+		t.Fatalf("Error loading model: %v", err)
+	}
+	if model.Info.Classes != 2 || model.Info.Path != "test_dir/model.zip" {
+		t.Errorf("Unexpected model info: %+v", model.Info)
+	}
+
+	classifier.Learn([]string{"loan"}, bayesian.Class("Mortgage"))
+	if err := util.WriteModelBundle("test_dir/model.zip", bundle); err != nil {
+		t.Fatalf("Error writing model bundle: %v", err)
+	}
+	if second, _ := source.Revision(); second == first {
+		t.Errorf("Expected revision to change when the model is replaced")
+	}
+}
+
+func TestServeHierarchicalModel(t *testing.T) {
+	root := bayesian.NewClassifier(bayesian.Class("Credit card"), bayesian.Class("Mortgage"))
+	root.Learn([]string{"card"}, bayesian.Class("Credit card"))
+	root.Learn([]string{"mortgage", "veteran", "fha"}, bayesian.Class("Mortgage"))
+	child := bayesian.NewClassifier(bayesian.Class("FHA mortgage"), bayesian.Class("VA mortgage"))
+	child.Learn([]string{"fha"}, bayesian.Class("FHA mortgage"))
+	child.Learn([]string{"veteran"}, bayesian.Class("VA mortgage"))
+	hierarchy := &util.HierarchicalModel{
+		Root:           root,
+		Children:       map[string]*bayesian.Classifier{"Mortgage": child},
+		SingleChildren: map[string]string{"Credit card": "Store card"},
+	}
+	bundle := &util.ModelBundle{Manifest: util.ModelManifest{Mapping: util.DefaultFieldMapping()}, Hierarchy: hierarchy}
+	if err := util.WriteModelBundle("test_dir/model.zip", bundle); err != nil {
+		t.Fatalf("Error writing model bundle: %v", err)
+	}
+	defer os.RemoveAll("test_dir")
+
+	srv, err := NewServerFromSource(BundleSource{Resolve: func() (string, error) { return "test_dir/model.zip", nil }}, util.AbstentionPolicy{}, "")
+	if err != nil {
+		t.Fatalf("Error serving hierarchical model: %v", err)
+	}
+	if srv.Model().Info.Kind != util.MODEL_KIND_HIERARCHICAL || srv.Model().Info.Classes != 2 {
+		t.Errorf("Unexpected model info: %+v", srv.Model().Info)
+	}
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/classify", bytes.NewReader([]byte(`{"issue": "veteran mortgage"}`))))
+	var response ClassifyResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if response.Product != "Mortgage" || response.Path == nil || !reflect.DeepEqual(response.Path.Path, []string{"Mortgage", "VA mortgage"}) {
+		t.Errorf("Expected path to the sub-product, got %+v", response)
+	}
+}
@@ -0,0 +1,159 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils"
+)
+
+const (
+	// Size of the body of a single ticket request in bytes.
+	MAX_TICKET_BYTES = 1 << 20
+)
+
+type ClassifyResponse struct {
+	// The most likely product, or UNCERTAIN when the ticket needs a human review.
+	Product string `json:"product"`
+	// The most likely product even when the ticket needs a human review.
+	Candidate     string             `json:"candidate"`
+	NeedsReview   bool               `json:"needs_review"`
+	Reason        string             `json:"reason,omitempty"`
+	Probabilities map[string]float64 `json:"probabilities"`
+	Ranking       []util.Prediction  `json:"ranking"`
+	// Path from the candidate product to its sub-product, set by hierarchical models.
+	Path *util.PathPrediction `json:"path,omitempty"`
+}
+
+type ErrorResponse struct {
+	Error string `json:"error"`
+}
+
+// Serves predictions of a trained bayesian model, flat or hierarchical, over HTTP.
+type Server struct {
+	// The served *Model, swapped as a whole on reload so requests in flight finish on the model they started with.
+	model  atomic.Value
+	policy util.AbstentionPolicy
+	reload reloadState
+}
+
+func NewServer(predictor *util.Predictor, mapping models.FieldMapping, policy util.AbstentionPolicy) *Server {
+	s := &Server{policy: policy}
+	if predictor != nil {
+		s.setModel(&Model{predictor: predictor, mapping: mapping, Info: ModelInfo{Classes: len(predictor.Classes()), LoadedAt: time.Now().UTC()}})
+	}
+	return s
+}
+
+// Returns the router with all service endpoints registered. The admin endpoint is
+// registered only when an admin token is set.
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/v1/classify", s.handleClassify)
+	mux.HandleFunc("/v1/classify/batch", s.handleClassifyBatch)
+	mux.HandleFunc("/healthz", s.handleHealth)
+	mux.HandleFunc("/readyz", s.handleReady)
+	if s.reload.adminToken != "" {
+		mux.HandleFunc("/admin/reload", s.handleReload)
+	}
+	return mux
+}
+
+// Returns the served model, nil when none is loaded.
+func (s *Server) Model() *Model {
+	model, _ := s.model.Load().(*Model)
+	return model
+}
+
+func (s *Server) setModel(model *Model) {
+	s.model.Store(model)
+}
+
+func (s *Server) handleClassify(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	model := s.Model()
+	if model == nil {
+		writeError(w, http.StatusServiceUnavailable, "model is not loaded")
+		return
+	}
+
+	body := &countingReader{r: http.MaxBytesReader(w, r.Body, MAX_TICKET_BYTES)}
+	var ticket map[string]interface{}
+	if err := json.NewDecoder(body).Decode(&ticket); err != nil {
+		if body.n >= MAX_TICKET_BYTES {
+			writeError(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request body is larger than %d bytes", MAX_TICKET_BYTES))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "request body is not a valid ticket")
+		return
+	}
+
+	text := util.MappedText(model.mapping, util.RecordFromJSON(ticket))
+	if text == "" {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("%s is required", strings.Join(util.TextFields(model.mapping), " or ")))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, s.classify(model, text))
+}
+
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if s.Model() == nil {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]string{"status": "model not loaded"})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ready"})
+}
+
+// Ranks every class for the ticket text, built from the ticket fields the model was trained with,
+// and marks the ticket for a review when the abstention policy is not met.
+func (s *Server) classify(model *Model, text string) ClassifyResponse {
+	ranking := model.predictor.Predict(text)
+
+	probabilities := make(map[string]float64, len(ranking))
+	for _, p := range ranking {
+		probabilities[p.Class] = p.Probability
+	}
+
+	decision := s.policy.Decide(ranking)
+
+	response := ClassifyResponse{
+		Product:       decision.Class,
+		Candidate:     decision.Candidate,
+		NeedsReview:   decision.Uncertain,
+		Reason:        decision.Reason,
+		Probabilities: probabilities,
+		Ranking:       ranking,
+	}
+	if model.hierarchy != nil {
+		path := model.hierarchy.PathFrom(text, ranking[0])
+		response.Path = &path
+	}
+	return response
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Print("Can not write response: ", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, ErrorResponse{Error: message})
+}
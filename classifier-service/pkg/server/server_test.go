@@ -0,0 +1,189 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils"
+	"github.com/navossoc/bayesian"
+)
+
+// Returns the tokenizer of the zero config, which is always valid.
+func newTestTokenizer() *util.Tokenizer {
+	tokenizer, err := util.NewTokenizer(util.TokenizerConfig{})
+	if err != nil {
+		panic(err)
+	}
+	return tokenizer
+}
+
+func newTestServer() *Server {
+	return newTestServerWithPolicy(util.AbstentionPolicy{})
+}
+
+func newTestServerWithPolicy(policy util.AbstentionPolicy) *Server {
+	classifier := bayesian.NewClassifier(bayesian.Class("Mortgage"), bayesian.Class("Credit card"))
+	classifier.Learn([]string{"mortgage", "loan", "escrow", "payment"}, bayesian.Class("Mortgage"))
+	classifier.Learn([]string{"card", "charge", "fee", "statement"}, bayesian.Class("Credit card"))
+	stopWords := map[string]struct{}{"the": {}, "my": {}}
+	return NewServer(util.NewPredictor(classifier, stopWords, newTestTokenizer()), util.DefaultFieldMapping(), policy)
+}
+
+func TestClassify(t *testing.T) {
+	srv := newTestServer()
+	body := []byte(`{"issue": "Escrow payment", "complaint_what_happened": "The mortgage escrow is wrong"}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/classify", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	srv.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var response ClassifyResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if response.Product != "Mortgage" {
+		t.Errorf("Expected Mortgage, got %s", response.Product)
+	}
+	if len(response.Probabilities) != 2 {
+		t.Errorf("Expected probabilities for 2 classes, got %v", response.Probabilities)
+	}
+	if response.Probabilities["Mortgage"] <= response.Probabilities["Credit card"] {
+		t.Errorf("Expected Mortgage to be more probable, got %v", response.Probabilities)
+	}
+	if len(response.Ranking) != 2 || response.Ranking[1].Class != "Credit card" {
+		t.Errorf("Expected Credit card to be ranked second, got %v", response.Ranking)
+	}
+	if response.NeedsReview || response.Candidate != "Mortgage" {
+		t.Errorf("Expected confident Mortgage prediction, got %+v", response)
+	}
+}
+
+func TestClassifyUncertain(t *testing.T) {
+	srv := newTestServerWithPolicy(util.AbstentionPolicy{MinProbability: 0.99999})
+	body := []byte(`{"issue": "Escrow payment", "complaint_what_happened": "My card statement"}`)
+	req := httptest.NewRequest(http.MethodPost, "/v1/classify", bytes.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	srv.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	var response ClassifyResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if response.Product != util.UNCERTAIN_CLASS || !response.NeedsReview {
+		t.Errorf("Expected ticket to need a review, got %+v", response)
+	}
+	if response.Candidate == "" || response.Reason == "" {
+		t.Errorf("Expected candidate and reason of the uncertain prediction, got %+v", response)
+	}
+}
+
+func TestClassifyInvalidBody(t *testing.T) {
+	srv := newTestServer()
+	req := httptest.NewRequest(http.MethodPost, "/v1/classify", bytes.NewReader([]byte(`{"issue": `)))
+	rec := httptest.NewRecorder()
+
+	srv.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestClassifyBodyTooLarge(t *testing.T) {
+	srv := newTestServer()
+	body := `{"issue": "` + strings.Repeat("escrow ", MAX_TICKET_BYTES/7) + `"}`
+	req := httptest.NewRequest(http.MethodPost, "/v1/classify", strings.NewReader(body))
+	rec := httptest.NewRecorder()
+
+	srv.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("Expected status %d, got %d", http.StatusRequestEntityTooLarge, rec.Code)
+	}
+}
+
+func TestClassifyEmptyTicket(t *testing.T) {
+	srv := newTestServer()
+	req := httptest.NewRequest(http.MethodPost, "/v1/classify", bytes.NewReader([]byte(`{"product": "Mortgage"}`)))
+	rec := httptest.NewRecorder()
+
+	srv.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d, got %d", http.StatusBadRequest, rec.Code)
+	}
+}
+
+func TestClassifyWithModelMapping(t *testing.T) {
+	srv := newTestServer()
+	srv.Model().mapping = models.FieldMapping{Label: "queue", Text: []models.TextField{{Field: "subject"}, {Field: "body"}}}
+
+	rec := httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/classify", bytes.NewReader([]byte(`{"subject": "Escrow", "body": "mortgage payment"}`))))
+	var response ClassifyResponse
+	if err := json.NewDecoder(rec.Body).Decode(&response); err != nil {
+		t.Fatalf("Error decoding response: %v", err)
+	}
+	if rec.Code != http.StatusOK || response.Product != "Mortgage" {
+		t.Errorf("Expected ticket text to be built from the model mapping, got %d %+v", rec.Code, response)
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/v1/classify", bytes.NewReader([]byte(`{"issue": "Escrow"}`))))
+	var errorResponse ErrorResponse
+	json.NewDecoder(rec.Body).Decode(&errorResponse)
+	if rec.Code != http.StatusBadRequest || errorResponse.Error != "subject or body is required" {
+		t.Errorf("Expected fields of the mapping to be required, got %d %+v", rec.Code, errorResponse)
+	}
+}
+
+func TestClassifyWrongMethod(t *testing.T) {
+	srv := newTestServer()
+	req := httptest.NewRequest(http.MethodGet, "/v1/classify", nil)
+	rec := httptest.NewRecorder()
+
+	srv.Handler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status %d, got %d", http.StatusMethodNotAllowed, rec.Code)
+	}
+}
+
+func TestHealth(t *testing.T) {
+	srv := NewServer(nil, util.DefaultFieldMapping(), util.AbstentionPolicy{})
+	rec := httptest.NewRecorder()
+
+	srv.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+}
+
+func TestReady(t *testing.T) {
+	rec := httptest.NewRecorder()
+	newTestServer().Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d, got %d", http.StatusOK, rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	NewServer(nil, util.DefaultFieldMapping(), util.AbstentionPolicy{}).Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d when model is not loaded, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils"
+)
+
+const (
+	DEFAULT_TEST_RATIO = 0.2
+)
+
+func runEvaluate(args []string) int {
+	var p paths
+	fs := newFlagSet("evaluate")
+	p.registerStopWords(fs)
+	p.registerTrainData(fs)
+	p.registerTokenizer(fs)
+	folds := fs.Int("folds", 0, "number of random folds for cross-validation, held-out split is used when not set")
+	testRatio := fs.Float64("test-ratio", envFloat("TEST_RATIO", DEFAULT_TEST_RATIO), "share of held-out test data, overrides TEST_RATIO")
+	reportFileDir := fs.String("report", util.GetEnvVariable("REPORT_FILE_DIR"), "JSON report file, overrides REPORT_FILE_DIR")
+	compare := fs.String("compare", "", "compare tokenizer variants on the same split, e.g. term_frequency=binary,raw,log or morphology=lemmatize,stem, fails when the best macro F1 is below --min-macro-f1")
+	seed := fs.Int64("seed", envInt64("RANDOM_SEED", 0), "seed of the random split and folds, a random seed is used when 0, overrides RANDOM_SEED")
+	minMacroF1 := fs.Float64("min-macro-f1", envFloat("MIN_MACRO_F1", 0), "fail when macro F1 is below the value, overrides MIN_MACRO_F1")
+
+	if ok, code := parseFlags(fs, args, map[string]*string{"stop-words": &p.stopWordsDir, "train-data": &p.trainDataDir}); !ok {
+		return code
+	}
+
+	if *testRatio <= 0 || *testRatio >= 1 {
+		log.Print("--test-ratio must be a number between 0 and 1")
+		return EXIT_USAGE
+	}
+
+	dataset, err := p.dataset()
+	if err != nil {
+		log.Print("Can not read field mapping: ", err)
+		return EXIT_USAGE
+	}
+
+	tokenizer, err := p.tokenizer()
+	if err != nil {
+		log.Print("Invalid tokenizer config: ", err)
+		return EXIT_USAGE
+	}
+
+	var variants []util.TokenizerVariant
+	if *compare != "" {
+		if *folds > 0 {
+			log.Print("--compare can not be used with --folds")
+			return EXIT_USAGE
+		}
+		if variants, err = util.ParseTokenizerVariants(tokenizer.Config(), *compare); err != nil {
+			log.Print("Invalid --compare: ", err)
+			return EXIT_USAGE
+		}
+	}
+
+	stopWords, err := util.ReadStopWordLists(p.stopWordsDir)
+	if err != nil {
+		log.Print("Reading stop words failed: ", err)
+		return EXIT_FAILURE
+	}
+
+	if *seed == 0 {
+		*seed = time.Now().UnixNano()
+	}
+
+	var report interface{}
+	var macroF1 float64
+
+	if *folds > 0 {
+		log.Printf("Cross-validating with seed %d and %d folds", *seed, *folds)
+		cvReport, err := util.CrossValidate(dataset, stopWords, tokenizer.Config(), *folds, *seed)
+		if err != nil {
+			log.Print("Cross-validation failed: ", err)
+			return EXIT_FAILURE
+		}
+		report, macroF1 = cvReport, cvReport.MacroF1.Mean
+	} else if len(variants) > 0 {
+		log.Printf("Comparing %d tokenizer variants with seed %d and test ratio %.2f", len(variants), *seed, *testRatio)
+		comparison, err := util.CompareTokenizers(dataset, stopWords, variants, *testRatio, *seed)
+		if err != nil {
+			log.Print("Comparing tokenizers failed: ", err)
+			return EXIT_FAILURE
+		}
+		for _, c := range comparison {
+			if c.Report.MacroF1 > macroF1 {
+				macroF1 = c.Report.MacroF1
+			}
+		}
+		report = comparison
+	} else {
+		log.Printf("Splitting data with seed %d and test ratio %.2f", *seed, *testRatio)
+		evalReport, err := util.HoldoutEvaluate(dataset, stopWords, tokenizer, *testRatio, *seed)
+		if err != nil {
+			log.Print("Evaluation failed: ", err)
+			return EXIT_FAILURE
+		}
+		report, macroF1 = evalReport, evalReport.MacroF1
+	}
+
+	fmt.Print(report)
+
+	if *reportFileDir != "" {
+		if err := util.WriteReportToFile(*reportFileDir, report); err != nil {
+			log.Print("Can not write report to file: ", err)
+			return EXIT_FAILURE
+		}
+		log.Printf("Report written to %s", *reportFileDir)
+	}
+
+	if macroF1 < *minMacroF1 {
+		log.Printf("Macro F1 %.4f is below required %.4f", macroF1, *minMacroF1)
+		return EXIT_QUALITY_FAILED
+	}
+	return EXIT_OK
+}
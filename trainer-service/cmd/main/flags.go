@@ -0,0 +1,182 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils"
+)
+
+// Paths shared by commands. Flags default to the environment variables they override.
+type paths struct {
+	stopWordsDir string
+	trainDataDir string
+	modelFileDir string
+	registryDir  string
+	dataFormat   string
+	mappingFile  string
+	labelField   string
+	childField   string
+	// Tokenizer config file and options overriding it.
+	tokenizerFile    string
+	ngrams           int
+	termFrequency    string
+	maxTermFrequency int
+	normalize        bool
+	morphology       string
+	// Flag set the tokenizer options were registered with, to tell flags passed explicitly.
+	tokenizerFlags *flag.FlagSet
+}
+
+func newFlagSet(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	return fs
+}
+
+func (p *paths) registerStopWords(fs *flag.FlagSet) {
+	fs.StringVar(&p.stopWordsDir, "stop-words", util.GetEnvVariable("STOP_WORDS_DIR"), "stop words file, overrides STOP_WORDS_DIR")
+}
+
+func (p *paths) registerTrainData(fs *flag.FlagSet) {
+	fs.StringVar(&p.trainDataDir, "train-data", util.GetEnvVariable("TRAIN_DATA_DIR"), "training data file, overrides TRAIN_DATA_DIR")
+	fs.StringVar(&p.dataFormat, "format", util.GetEnvVariable("TRAIN_DATA_FORMAT"), "training data format: json, jsonl or csv, detected from the file extension when empty, overrides TRAIN_DATA_FORMAT")
+	fs.StringVar(&p.mappingFile, "mapping", util.GetEnvVariable("FIELD_MAPPING_FILE"), "JSON file mapping training data fields onto label and text, overrides FIELD_MAPPING_FILE")
+	fs.StringVar(&p.labelField, "label-field", "", "training data field with the label, overrides label of the mapping")
+	fs.StringVar(&p.childField, "child-label-field", "", "training data field with the child label of hierarchical models, overrides child label of the mapping")
+}
+
+func (p *paths) registerModel(fs *flag.FlagSet) {
+	fs.StringVar(&p.modelFileDir, "model", util.GetEnvVariable("MODEL_FILE_DIR"), "model file, overrides MODEL_FILE_DIR")
+}
+
+func (p *paths) registerRegistry(fs *flag.FlagSet) {
+	fs.StringVar(&p.registryDir, "registry", util.GetEnvVariable("MODEL_REGISTRY_DIR"), "model registry directory, overrides MODEL_REGISTRY_DIR")
+}
+
+func (p *paths) registerTokenizer(fs *flag.FlagSet) {
+	p.tokenizerFlags = fs
+	fs.StringVar(&p.tokenizerFile, "tokenizer", util.GetEnvVariable("TOKENIZER_CONFIG_FILE"), "JSON file with tokenizer config, overrides TOKENIZER_CONFIG_FILE")
+	fs.IntVar(&p.ngrams, "ngrams", 0, fmt.Sprintf("longest word n-gram to learn, from 1 to %d, overrides ngrams of the tokenizer config", util.MAX_NGRAMS))
+	fs.StringVar(&p.termFrequency, "term-frequency", "", "how repeated tokens are counted: binary, raw, log or cap, overrides term_frequency of the tokenizer config")
+	fs.IntVar(&p.maxTermFrequency, "max-term-frequency", 0, "maximum count of a token per text with cap term frequency, overrides max_term_frequency of the tokenizer config")
+	fs.BoolVar(&p.normalize, "normalize", false, "replace amounts, dates, masked values and personal data with placeholders, overrides normalize of the tokenizer config")
+	fs.StringVar(&p.morphology, "morphology", "", "how words are reduced to their base form: lemmatize or stem, overrides morphology of the tokenizer config")
+}
+
+// Returns the tokenizer built from the config, read from the file if it is set.
+func (p *paths) tokenizer() (*util.Tokenizer, error) {
+	var config util.TokenizerConfig
+	if p.tokenizerFile != "" {
+		fromFile, err := util.ReadTokenizerConfig(p.tokenizerFile)
+		if err != nil {
+			return nil, err
+		}
+		config = fromFile
+	}
+	if p.ngrams != 0 {
+		config.NGrams = p.ngrams
+	}
+	if p.termFrequency != "" {
+		config.TermFrequency = p.termFrequency
+	}
+	if p.maxTermFrequency != 0 {
+		config.MaxTermFrequency = p.maxTermFrequency
+	}
+	// Unlike the other options, false is a valid value of --normalize, so it is applied
+	// whenever it is passed explicitly.
+	p.tokenizerFlags.Visit(func(f *flag.Flag) {
+		if f.Name == "normalize" {
+			config.Normalize = p.normalize
+		}
+	})
+	if p.morphology != "" {
+		config.Morphology = p.morphology
+	}
+	return util.NewTokenizer(config)
+}
+
+// Returns the training dataset, with the field mapping read from the file if it is set.
+func (p *paths) dataset() (util.Dataset, error) {
+	mapping := util.DefaultFieldMapping()
+	if p.mappingFile != "" {
+		fromFile, err := util.ReadFieldMapping(p.mappingFile)
+		if err != nil {
+			return util.Dataset{}, err
+		}
+		mapping = fromFile
+	}
+	if p.labelField != "" {
+		mapping.Label = p.labelField
+	}
+	if p.childField != "" {
+		mapping.ChildLabel = p.childField
+	}
+	return util.Dataset{Path: p.trainDataDir, Format: p.dataFormat, Mapping: mapping}, nil
+}
+
+// Checks that trained models have somewhere to go, the registry takes precedence over the model file.
+func (p *paths) modelDestination() bool {
+	if p.registryDir == "" && p.modelFileDir == "" {
+		log.Print("--model or --registry flag or their environment variables are empty")
+		return false
+	}
+	return true
+}
+
+// Parses arguments of the command and checks that required paths are set.
+// When the command can not proceed, returns false and the exit code to stop with.
+func parseFlags(fs *flag.FlagSet, args []string, required map[string]*string) (bool, int) {
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return false, EXIT_OK
+		}
+		return false, EXIT_USAGE
+	}
+
+	missing := false
+	for name, value := range required {
+		if *value == "" {
+			log.Printf("--%s flag or its environment variable is empty", name)
+			missing = true
+		}
+	}
+	if missing {
+		return false, EXIT_USAGE
+	}
+	return true, EXIT_OK
+}
+
+func envInt64(key string, defaultValue int64) int64 {
+	value := util.GetEnvVariable(key)
+	if value == "" {
+		return defaultValue
+	}
+	i, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		log.Printf("%s is not an integer, using %v", key, defaultValue)
+		return defaultValue
+	}
+	return i
+}
+
+func envFloat(key string, defaultValue float64) float64 {
+	value := util.GetEnvVariable(key)
+	if value == "" {
+		return defaultValue
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		log.Printf("%s is not a number, using %v", key, defaultValue)
+		return defaultValue
+	}
+	return f
+}
+
+func fileExists(fileName string) bool {
+	_, err := os.Stat(fileName)
+	return err == nil
+}
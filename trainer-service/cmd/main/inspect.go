@@ -0,0 +1,93 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils"
+)
+
+func runInspectModel(args []string) int {
+	var p paths
+	fs := newFlagSet("inspect-model")
+	p.registerModel(fs)
+	topWords := fs.Int("top-words", 10, "number of most frequent words to print for every class")
+
+	if ok, code := parseFlags(fs, args, map[string]*string{"model": &p.modelFileDir}); !ok {
+		return code
+	}
+
+	if !fileExists(p.modelFileDir) {
+		log.Printf("Model %s does not exist, run 'train' first", p.modelFileDir)
+		return EXIT_MODEL_MISSING
+	}
+
+	bundle, err := util.ReadModelBundle(p.modelFileDir)
+	if err != nil {
+		log.Print("Can not read model: ", err)
+		return EXIT_FAILURE
+	}
+	manifest := bundle.Manifest
+
+	fmt.Printf("Model:       %s\n", p.modelFileDir)
+	fmt.Printf("Schema:      %d, %s\n", manifest.SchemaVersion, manifest.Kind)
+	fmt.Printf("Created:     %s\n", manifest.CreatedAt.Format(time.RFC3339))
+	fmt.Printf("Fingerprint: %s\n", manifest.Fingerprint.Fingerprint)
+	fmt.Printf("Version:     %s\n", manifest.Fingerprint.Version)
+	fmt.Printf("Text fields: %s\n", strings.Join(util.TextFields(manifest.Mapping), ", "))
+	fmt.Printf("Tokenizer:   %+v\n", bundle.Tokenizer)
+	if report, ok := manifest.EvaluationReport(); ok {
+		fmt.Printf("Metrics:     accuracy %.4f, macro F1 %.4f on %d samples\n", report.Accuracy, report.MacroF1, report.Samples)
+	} else if len(manifest.Metrics) > 0 {
+		fmt.Printf("Metrics:     %d bytes of JSON\n", len(manifest.Metrics))
+	}
+
+	classifier := bundle.Classifier
+	if manifest.Kind == util.MODEL_KIND_HIERARCHICAL {
+		classifier = bundle.Hierarchy.Root
+		fmt.Printf("Children:    %d classifiers\n", len(bundle.Hierarchy.Children))
+	}
+	fmt.Printf("Classes:     %d\n", len(classifier.Classes))
+	if manifest.Tickets != nil {
+		tickets := 0
+		for _, count := range manifest.Tickets {
+			tickets += count
+		}
+		fmt.Printf("Tickets:     %d\n", tickets)
+	}
+	fmt.Println()
+
+	wordCounts := classifier.WordCount()
+	for i, class := range classifier.Classes {
+		words := classifier.WordsByClass(class)
+		if count, ok := manifest.Tickets[string(class)]; ok {
+			fmt.Printf("%s (%d tickets, %d words, %d unique)\n", class, count, wordCounts[i], len(words))
+		} else {
+			fmt.Printf("%s (%d words, %d unique)\n", class, wordCounts[i], len(words))
+		}
+		if *topWords > 0 {
+			fmt.Printf("  %s\n", strings.Join(mostFrequent(words, *topWords), ", "))
+		}
+	}
+	return EXIT_OK
+}
+
+func mostFrequent(words map[string]float64, n int) []string {
+	result := make([]string, 0, len(words))
+	for w := range words {
+		result = append(result, w)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if words[result[i]] == words[result[j]] {
+			return result[i] < result[j]
+		}
+		return words[result[i]] > words[result[j]]
+	})
+	if len(result) > n {
+		result = result[:n]
+	}
+	return result
+}
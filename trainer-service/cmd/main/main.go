@@ -1,44 +1,71 @@
 package main
 
 import (
+	"fmt"
 	"log"
 	"os"
 
-	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer/pkg/utils"
-	"github.com/navossoc/bayesian"
+	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils"
 )
 
-var classifier *bayesian.Classifier
+// Exit codes of the trainer CLI.
+const (
+	EXIT_OK             = 0
+	EXIT_FAILURE        = 1
+	EXIT_USAGE          = 2
+	EXIT_MODEL_EXISTS   = 3
+	EXIT_MODEL_MISSING  = 4
+	EXIT_QUALITY_FAILED = 5
+)
 
-func main() {
-	errLoadinEnv := util.LoadEnvFile()
+type command struct {
+	name        string
+	description string
+	run         func(args []string) int
+}
 
-	if errLoadinEnv != nil {
-		log.Fatalf("Error loading .env file")
-		os.Exit(1)
+var commands = []command{
+	{"train", "train a new model if there is none yet", runTrain},
+	{"retrain", "train a new model replacing the existing one, requires --force", runRetrain},
+	{"evaluate", "evaluate the model on a held-out split or with k-fold cross-validation", runEvaluate},
+	{"predict", "classify a ticket text with the trained model", runPredict},
+	{"inspect-model", "print classes and learned words of the trained model", runInspectModel},
+	{"list-models", "list model versions of the registry", runListModels},
+	{"promote-model", "serve a version of the registry in production", runPromoteModel},
+	{"rollback-model", "return production to the previously promoted version", runRollbackModel},
+	{"diff-models", "compare classes, training inputs and metrics of two versions", runDiffModels},
+	{"discover-stop-words", "propose stop words without class signal found in the training data", runDiscoverStopWords},
+}
+
+func main() {
+	if errLoadinEnv := util.LoadEnvFile(); errLoadinEnv != nil {
+		log.Print("No .env file found, using flags and process environment")
 	}
 
-	stopWordsDir := util.GetEnvVariable("STOP_WORDS_DIR")
-	trainDataDir := util.GetEnvVariable("TRAIN_DATA_DIR")
-	modelFileDir := util.GetEnvVariable("MODEL_FILE_DIR")
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(EXIT_USAGE)
+	}
 
-	if stopWordsDir == "" || trainDataDir == "" || modelFileDir == "" {
-		if stopWordsDir == "" {
-			log.Print("STOP_WORDS_DIR is empty")
-		}
-		if trainDataDir == "" {
-			log.Print("TRAIN_DATA_DIR is empty")
-		}
-		if modelFileDir == "" {
-			log.Print("MODEL_FILE_DIR is empty")
+	name := os.Args[1]
+	for _, c := range commands {
+		if c.name == name {
+			os.Exit(c.run(os.Args[2:]))
 		}
-		os.Exit(1)
 	}
 
-	_, err := util.GetBaseModel(modelFileDir, trainDataDir, stopWordsDir)
+	if name != "help" && name != "-h" && name != "--help" {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", name)
+		usage()
+		os.Exit(EXIT_USAGE)
+	}
+	usage()
+}
 
-	if err != nil {
-		log.Print("Running trainer failed. Stopping.")
-		os.Exit(1)
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n\nCommands:\n", os.Args[0])
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-20s %s\n", c.name, c.description)
 	}
+	fmt.Fprintf(os.Stderr, "\nRun '%s <command> -h' to see flags of the command.\n", os.Args[0])
 }
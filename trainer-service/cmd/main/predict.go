@@ -0,0 +1,102 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils"
+)
+
+func runPredict(args []string) int {
+	var p paths
+	fs := newFlagSet("predict")
+	p.registerModel(fs)
+	topK := fs.Int("top-k", 3, "number of most likely classes to print, all classes when not positive, hierarchical models print the most likely path of classes")
+	policyFileDir := fs.String("policy", util.GetEnvVariable("ABSTENTION_POLICY_FILE"), "JSON file with abstention policy, overrides ABSTENTION_POLICY_FILE")
+	minProbability := fs.Float64("min-probability", envFloat("MIN_PROBABILITY", 0), "print UNCERTAIN when probability of the most likely class is below the value, overrides MIN_PROBABILITY")
+	minMargin := fs.Float64("min-margin", envFloat("MIN_MARGIN", 0), "print UNCERTAIN when the two most likely classes differ less than the value, overrides MIN_MARGIN")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: predict [flags] [text], text is read from stdin when not given")
+		fs.PrintDefaults()
+	}
+
+	if ok, code := parseFlags(fs, args, map[string]*string{"model": &p.modelFileDir}); !ok {
+		return code
+	}
+
+	text := strings.Join(fs.Args(), " ")
+	if text == "" {
+		bytes, err := ioutil.ReadAll(os.Stdin)
+		if err != nil {
+			log.Print("Can not read text from stdin: ", err)
+			return EXIT_FAILURE
+		}
+		text = string(bytes)
+	}
+	if strings.TrimSpace(text) == "" {
+		log.Print("Nothing to classify, pass text as arguments or to stdin")
+		return EXIT_USAGE
+	}
+
+	if !fileExists(p.modelFileDir) {
+		log.Printf("Model %s does not exist, run 'train' first", p.modelFileDir)
+		return EXIT_MODEL_MISSING
+	}
+
+	bundle, err := util.ReadModelBundle(p.modelFileDir)
+	if err != nil {
+		log.Print("Can not read model: ", err)
+		return EXIT_FAILURE
+	}
+
+	// MIN_PROBABILITY and MIN_MARGIN override values of the policy file,
+	// and thresholds passed explicitly override both.
+	policy, err := util.LoadAbstentionPolicy(*policyFileDir)
+	if err != nil {
+		log.Print("Can not read abstention policy: ", err)
+		return EXIT_FAILURE
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "min-probability":
+			policy.MinProbability = *minProbability
+		case "min-margin":
+			policy.MinMargin = *minMargin
+		}
+	})
+
+	if bundle.Manifest.Kind == util.MODEL_KIND_HIERARCHICAL {
+		predictor, err := bundle.HierarchicalPredictor()
+		if err != nil {
+			log.Print("Can not create predictor: ", err)
+			return EXIT_FAILURE
+		}
+		// Like the server, the policy decides on the ranking of root classes.
+		ranking := predictor.Root().Predict(text)
+		if decision := policy.Decide(ranking); decision.Uncertain {
+			fmt.Printf("%s\t%s\n", util.UNCERTAIN_CLASS, decision.Reason)
+		}
+		prediction := predictor.PathFrom(text, ranking[0])
+		fmt.Printf("%.4f\t%s\n", prediction.Confidence, prediction.Leaf())
+		return EXIT_OK
+	}
+
+	predictor, err := bundle.Predictor()
+	if err != nil {
+		log.Print("Can not create predictor: ", err)
+		return EXIT_FAILURE
+	}
+	decision := policy.Decide(predictor.Predict(text))
+	if decision.Uncertain {
+		fmt.Printf("%s\t%s\n", util.UNCERTAIN_CLASS, decision.Reason)
+	}
+
+	for _, prediction := range predictor.PredictTopK(text, *topK) {
+		fmt.Printf("%.4f\t%s\n", prediction.Probability, prediction.Class)
+	}
+	return EXIT_OK
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils"
+)
+
+func runListModels(args []string) int {
+	var p paths
+	fs := newFlagSet("list-models")
+	p.registerRegistry(fs)
+
+	if ok, code := parseFlags(fs, args, map[string]*string{"registry": &p.registryDir}); !ok {
+		return code
+	}
+
+	versions, err := util.NewModelRegistry(p.registryDir).Versions()
+	if err != nil {
+		log.Print("Can not read registry: ", err)
+		return EXIT_FAILURE
+	}
+	if len(versions) == 0 {
+		log.Printf("Registry %s has no versions, run 'train --registry %s' first", p.registryDir, p.registryDir)
+		return EXIT_MODEL_MISSING
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Version\tStatus\tCreated\tKind\tClasses\tAccuracy\tMacro F1\tFingerprint")
+	for _, v := range versions {
+		status := ""
+		if v.Production {
+			status = util.VERSION_PRODUCTION
+		}
+		accuracy, macroF1 := "-", "-"
+		if report, ok := v.Manifest.EvaluationReport(); ok {
+			accuracy, macroF1 = fmt.Sprintf("%.4f", report.Accuracy), fmt.Sprintf("%.4f", report.MacroF1)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\t%s\t%s\n", v.ID, status, v.Manifest.CreatedAt.Format(time.RFC3339),
+			v.Manifest.Kind, len(v.Manifest.Classes), accuracy, macroF1, shortFingerprint(v.Manifest.Fingerprint.Fingerprint))
+	}
+	w.Flush()
+	return EXIT_OK
+}
+
+func runPromoteModel(args []string) int {
+	var p paths
+	fs := newFlagSet("promote-model")
+	p.registerRegistry(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: promote-model [flags] <version>, version is an id from 'list-models' or latest")
+		fs.PrintDefaults()
+	}
+
+	if ok, code := parseFlags(fs, args, map[string]*string{"registry": &p.registryDir}); !ok {
+		return code
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+
+	version, err := util.NewModelRegistry(p.registryDir).Promote(fs.Arg(0))
+	if err != nil {
+		log.Print("Can not promote model: ", err)
+		return registryExitCode(err)
+	}
+	log.Printf("Version %s with fingerprint %s is in production", version.ID, version.Manifest.Fingerprint.Fingerprint)
+	return EXIT_OK
+}
+
+func runRollbackModel(args []string) int {
+	var p paths
+	fs := newFlagSet("rollback-model")
+	p.registerRegistry(fs)
+
+	if ok, code := parseFlags(fs, args, map[string]*string{"registry": &p.registryDir}); !ok {
+		return code
+	}
+
+	version, err := util.NewModelRegistry(p.registryDir).Rollback()
+	if err != nil {
+		log.Print("Can not roll back model: ", err)
+		return registryExitCode(err)
+	}
+	log.Printf("Rolled back, version %s with fingerprint %s is in production", version.ID, version.Manifest.Fingerprint.Fingerprint)
+	return EXIT_OK
+}
+
+func runDiffModels(args []string) int {
+	var p paths
+	fs := newFlagSet("diff-models")
+	p.registerRegistry(fs)
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: diff-models [flags] <from> [to], versions are ids from 'list-models', production or latest, to is latest when not given")
+		fs.PrintDefaults()
+	}
+
+	if ok, code := parseFlags(fs, args, map[string]*string{"registry": &p.registryDir}); !ok {
+		return code
+	}
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return EXIT_USAGE
+	}
+	toRef := util.VERSION_LATEST
+	if fs.NArg() == 2 {
+		toRef = fs.Arg(1)
+	}
+
+	registry := util.NewModelRegistry(p.registryDir)
+	from, err := registry.Version(fs.Arg(0))
+	if err != nil {
+		log.Print("Can not read model: ", err)
+		return registryExitCode(err)
+	}
+	to, err := registry.Version(toRef)
+	if err != nil {
+		log.Print("Can not read model: ", err)
+		return registryExitCode(err)
+	}
+
+	fmt.Print(util.DiffModelVersions(from, to))
+	return EXIT_OK
+}
+
+func registryExitCode(err error) int {
+	if errors.Is(err, util.ErrVersionNotFound) {
+		return EXIT_MODEL_MISSING
+	}
+	return EXIT_FAILURE
+}
+
+func shortFingerprint(fingerprint string) string {
+	if len(fingerprint) > 12 {
+		return fingerprint[:12]
+	}
+	return fingerprint
+}
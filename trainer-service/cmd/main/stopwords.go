@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils"
+)
+
+const (
+	DEFAULT_MIN_DOCUMENT_FREQUENCY = 0.05
+	DEFAULT_MIN_CLASS_ENTROPY      = 0.9
+)
+
+func runDiscoverStopWords(args []string) int {
+	var p paths
+	fs := newFlagSet("discover-stop-words")
+	p.registerStopWords(fs)
+	p.registerTrainData(fs)
+	output := fs.String("output", "", "file to write proposed stop words to, in the format of the stop words file")
+	statsFileDir := fs.String("stats", "", "JSON file with statistics of proposed stop words, <output>.stats.json when empty")
+	minDocumentFrequency := fs.Float64("min-df", DEFAULT_MIN_DOCUMENT_FREQUENCY, "minimum share of tickets with the word")
+	minClassEntropy := fs.Float64("min-entropy", DEFAULT_MIN_CLASS_ENTROPY, "minimum normalized class entropy of the word, 1 is no class signal at all")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: discover-stop-words [flags], words of --stop-words are not proposed again")
+		fs.PrintDefaults()
+	}
+
+	if ok, code := parseFlags(fs, args, map[string]*string{"train-data": &p.trainDataDir, "output": output}); !ok {
+		return code
+	}
+	if *statsFileDir == "" {
+		*statsFileDir = strings.TrimSuffix(*output, ".json") + ".stats.json"
+	}
+
+	dataset, err := p.dataset()
+	if err != nil {
+		log.Print("Can not read field mapping: ", err)
+		return EXIT_USAGE
+	}
+
+	known := map[string]struct{}{}
+	if p.stopWordsDir != "" {
+		if known, err = util.ReadStopWords(p.stopWordsDir); err != nil {
+			log.Print("Can not read stop words: ", err)
+			return EXIT_FAILURE
+		}
+	}
+
+	discovery := util.StopWordDiscovery{MinDocumentFrequency: *minDocumentFrequency, MinClassEntropy: *minClassEntropy}
+	candidates, err := discovery.Discover(dataset, known)
+	if err != nil {
+		log.Print("Stop word discovery failed: ", err)
+		return EXIT_FAILURE
+	}
+
+	stopWords := make([]string, len(candidates))
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "Word\tDocuments\tDocument frequency\tClass entropy")
+	for i, c := range candidates {
+		stopWords[i] = c.Word
+		fmt.Fprintf(w, "%s\t%d\t%.4f\t%.4f\n", c.Word, c.Documents, c.DocumentFrequency, c.ClassEntropy)
+	}
+	w.Flush()
+
+	if err := util.WriteStopWordsToFile(*output, stopWords); err != nil {
+		log.Print("Can not write stop words: ", err)
+		return EXIT_FAILURE
+	}
+	if err := util.WriteReportToFile(*statsFileDir, candidates); err != nil {
+		log.Print("Can not write statistics: ", err)
+		return EXIT_FAILURE
+	}
+
+	log.Printf("%d proposed stop words written to %s, statistics to %s", len(stopWords), *output, *statsFileDir)
+	return EXIT_OK
+}
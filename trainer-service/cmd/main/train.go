@@ -0,0 +1,165 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"time"
+
+	util "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils"
+)
+
+func runTrain(args []string) int {
+	var p paths
+	fs := newFlagSet("train")
+	p.registerStopWords(fs)
+	p.registerTrainData(fs)
+	p.registerModel(fs)
+	p.registerRegistry(fs)
+	p.registerTokenizer(fs)
+	hierarchical := fs.Bool("hierarchical", false, "train a two-level model of labels and child labels")
+	metricsFileDir := fs.String("metrics", "", "JSON evaluation report to store in the model bundle, e.g. the one written by 'evaluate --report', versions of the registry are evaluated on a held-out split when not set")
+
+	if ok, code := parseFlags(fs, args, map[string]*string{"stop-words": &p.stopWordsDir, "train-data": &p.trainDataDir}); !ok {
+		return code
+	}
+	if !p.modelDestination() {
+		return EXIT_USAGE
+	}
+
+	if p.registryDir == "" && fileExists(p.modelFileDir) {
+		log.Printf("Model %s already exists, run 'retrain --force' to replace it", p.modelFileDir)
+		return EXIT_MODEL_EXISTS
+	}
+
+	return train(p, *hierarchical, *metricsFileDir)
+}
+
+func runRetrain(args []string) int {
+	var p paths
+	fs := newFlagSet("retrain")
+	p.registerStopWords(fs)
+	p.registerTrainData(fs)
+	p.registerModel(fs)
+	p.registerRegistry(fs)
+	p.registerTokenizer(fs)
+	force := fs.Bool("force", false, "replace the existing model")
+	hierarchical := fs.Bool("hierarchical", false, "train a two-level model of labels and child labels")
+	metricsFileDir := fs.String("metrics", "", "JSON evaluation report to store in the model bundle, e.g. the one written by 'evaluate --report', versions of the registry are evaluated on a held-out split when not set")
+
+	if ok, code := parseFlags(fs, args, map[string]*string{"stop-words": &p.stopWordsDir, "train-data": &p.trainDataDir}); !ok {
+		return code
+	}
+	if !p.modelDestination() {
+		return EXIT_USAGE
+	}
+
+	if p.registryDir == "" && fileExists(p.modelFileDir) && !*force {
+		log.Printf("Model %s already exists, add --force to replace it", p.modelFileDir)
+		return EXIT_MODEL_EXISTS
+	}
+
+	return train(p, *hierarchical, *metricsFileDir)
+}
+
+func train(p paths, hierarchical bool, metricsFileDir string) int {
+	dataset, err := p.dataset()
+	if err != nil {
+		log.Print("Can not read field mapping: ", err)
+		return EXIT_USAGE
+	}
+
+	tokenizer, err := p.tokenizer()
+	if err != nil {
+		log.Print("Invalid tokenizer config: ", err)
+		return EXIT_USAGE
+	}
+
+	var metrics json.RawMessage
+	if metricsFileDir != "" {
+		if metrics, err = ioutil.ReadFile(metricsFileDir); err != nil || !json.Valid(metrics) {
+			log.Printf("Can not read metrics from %s: %v", metricsFileDir, err)
+			return EXIT_USAGE
+		}
+	}
+
+	var bundle *util.ModelBundle
+	if hierarchical {
+		if dataset.Mapping.ChildLabel == "" {
+			log.Print("--child-label-field or child_label of the mapping is required for a hierarchical model")
+			return EXIT_USAGE
+		}
+		bundle, err = util.TrainHierarchicalModelBundle(dataset, p.stopWordsDir, tokenizer)
+	} else {
+		bundle, err = util.TrainModelBundle(dataset, p.stopWordsDir, tokenizer)
+	}
+	if err != nil {
+		log.Print("Training failed: ", err)
+		return EXIT_FAILURE
+	}
+
+	destination, versionID := p.modelFileDir, ""
+	if p.registryDir != "" {
+		// Every registered version gets metrics, so versions can be compared before promoting one.
+		if metrics == nil {
+			if metrics, err = holdoutMetrics(p, dataset, tokenizer, hierarchical); err != nil {
+				log.Print("Evaluation failed: ", err)
+				return EXIT_FAILURE
+			}
+		}
+		bundle.Manifest.Metrics = metrics
+		version, err := util.NewModelRegistry(p.registryDir).Register(bundle)
+		if err != nil {
+			log.Print("Can not register model: ", err)
+			return EXIT_FAILURE
+		}
+		destination, versionID = fmt.Sprintf("version %s of registry %s", version.ID, p.registryDir), version.ID
+	} else {
+		bundle.Manifest.Metrics = metrics
+		if err := util.WriteModelBundle(p.modelFileDir, bundle); err != nil {
+			log.Print("Can not write model: ", err)
+			return EXIT_FAILURE
+		}
+	}
+
+	if hierarchical {
+		log.Printf("Hierarchical model with %d classes and %d child classifiers written to %s", len(bundle.Hierarchy.Root.Classes), len(bundle.Hierarchy.Children), destination)
+	} else {
+		log.Printf("Model with %d classes written to %s", len(bundle.Classifier.Classes), destination)
+	}
+	if versionID != "" {
+		log.Printf("Run 'promote-model %s' to serve the version", versionID)
+	}
+	return EXIT_OK
+}
+
+// Evaluates the tokenizer on held-out tickets of the dataset, streaming it like training does.
+// Hierarchical models are evaluated on leaf classes of their paths.
+func holdoutMetrics(p paths, dataset util.Dataset, tokenizer *util.Tokenizer, hierarchical bool) (json.RawMessage, error) {
+	stopWords, err := util.ReadStopWordLists(p.stopWordsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	seed := envInt64("RANDOM_SEED", 0)
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	testRatio := envFloat("TEST_RATIO", DEFAULT_TEST_RATIO)
+	if testRatio <= 0 || testRatio >= 1 {
+		testRatio = DEFAULT_TEST_RATIO
+	}
+	log.Printf("Evaluating on held-out tickets with seed %d and test ratio %.2f", seed, testRatio)
+
+	evaluate := util.HoldoutEvaluate
+	if hierarchical {
+		evaluate = util.HierarchicalHoldoutEvaluate
+	}
+	report, err := evaluate(dataset, stopWords, tokenizer, testRatio, seed)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Accuracy %.4f, macro F1 %.4f on %d samples", report.Accuracy, report.MacroF1, report.Samples)
+	return json.Marshal(report)
+}
@@ -10,9 +10,52 @@ type FileTestDataSource struct {
 	Source FileTestData `json:"_source"`
 }
 
+// A single record of a dataset with values of its fields or columns.
+type Record map[string]string
+
+// A support case ready to be learned: its class, optional child class within
+// the class and the text to learn from.
+type TrainingCase struct {
+	Class      string
+	ChildClass string
+	Text       string
+}
+
+// A dataset field used as the text of support cases.
+type TextField struct {
+	Field string `json:"field"`
+	// Number of times the text of the field is repeated, 1 when not set.
+	Weight int `json:"weight,omitempty"`
+}
+
+// Keeps only records with the field value in Values, or drops them when Exclude is set.
+type FieldFilter struct {
+	Field   string   `json:"field"`
+	Values  []string `json:"values"`
+	Exclude bool     `json:"exclude,omitempty"`
+}
+
+// Describes which dataset fields are the label and the text of support cases.
+type FieldMapping struct {
+	Label string `json:"label"`
+	// Field with the child class within the label, used by hierarchical models.
+	ChildLabel string        `json:"child_label,omitempty"`
+	Text       []TextField   `json:"text"`
+	Filters    []FieldFilter `json:"filters,omitempty"`
+}
+
+// Stop words of a single class, applied together with global stop words.
 type StopWords struct {
 	Texts []string `json:"texts"`
 	Class string   `json:"class"`
+	// Global stop words the class learns from.
+	Keep []string `json:"keep,omitempty"`
+}
+
+// Stop words file with a global list and per class overrides.
+type StopWordsFile struct {
+	Global  []string    `json:"global"`
+	Classes []StopWords `json:"classes,omitempty"`
 }
 
 type Pair[T any, K any] struct {
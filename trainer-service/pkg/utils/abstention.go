@@ -0,0 +1,104 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+)
+
+// Outcome of a prediction not confident enough to route the ticket automatically.
+const UNCERTAIN_CLASS = "UNCERTAIN"
+
+// Decides when a prediction is too uncertain and the ticket needs a human review.
+// Zero values disable the corresponding check.
+type AbstentionPolicy struct {
+	// Minimum probability of the most likely class.
+	MinProbability float64 `json:"min_probability"`
+	// Minimum difference between probabilities of the two most likely classes.
+	MinMargin float64 `json:"min_margin"`
+	// Minimum probability per class, overrides MinProbability for the class.
+	ClassThresholds map[string]float64 `json:"class_thresholds,omitempty"`
+}
+
+type Decision struct {
+	// The most likely class, or UNCERTAIN_CLASS when the policy abstains.
+	Class string `json:"class"`
+	// The most likely class even when the policy abstains.
+	Candidate   string  `json:"candidate"`
+	Probability float64 `json:"probability"`
+	Margin      float64 `json:"margin"`
+	Uncertain   bool    `json:"uncertain"`
+	Reason      string  `json:"reason,omitempty"`
+}
+
+// Reads abstention policy from a JSON file.
+func ReadAbstentionPolicy(policyFileDir string) (AbstentionPolicy, error) {
+	var policy AbstentionPolicy
+
+	bytes, err := ioutil.ReadFile(policyFileDir)
+	if err != nil {
+		return policy, err
+	}
+
+	if err := json.Unmarshal(bytes, &policy); err != nil {
+		return policy, fmt.Errorf("not a valid abstention policy: %w", err)
+	}
+	return policy, nil
+}
+
+// Reads the abstention policy from the file when it is set,
+// MIN_PROBABILITY and MIN_MARGIN override the values of the file.
+func LoadAbstentionPolicy(policyFileDir string) (AbstentionPolicy, error) {
+	var policy AbstentionPolicy
+	var err error
+
+	if policyFileDir != "" {
+		if policy, err = ReadAbstentionPolicy(policyFileDir); err != nil {
+			return policy, err
+		}
+	}
+
+	if value := GetEnvVariable("MIN_PROBABILITY"); value != "" {
+		if policy.MinProbability, err = strconv.ParseFloat(value, 64); err != nil {
+			return policy, fmt.Errorf("MIN_PROBABILITY is not a number: %w", err)
+		}
+	}
+	if value := GetEnvVariable("MIN_MARGIN"); value != "" {
+		if policy.MinMargin, err = strconv.ParseFloat(value, 64); err != nil {
+			return policy, fmt.Errorf("MIN_MARGIN is not a number: %w", err)
+		}
+	}
+	return policy, nil
+}
+
+// Decides on ranked predictions, the most likely class first.
+func (p AbstentionPolicy) Decide(ranking []Prediction) Decision {
+	if len(ranking) == 0 {
+		return Decision{Class: UNCERTAIN_CLASS, Uncertain: true, Reason: "no predictions"}
+	}
+
+	top := ranking[0]
+	decision := Decision{Class: top.Class, Candidate: top.Class, Probability: top.Probability, Margin: top.Probability}
+	if len(ranking) > 1 {
+		decision.Margin = top.Probability - ranking[1].Probability
+	}
+
+	minProbability := p.MinProbability
+	if threshold, ok := p.ClassThresholds[top.Class]; ok {
+		minProbability = threshold
+	}
+
+	if decision.Probability < minProbability {
+		decision.Uncertain = true
+		decision.Reason = fmt.Sprintf("probability %.4f is below %.4f", decision.Probability, minProbability)
+	} else if decision.Margin < p.MinMargin {
+		decision.Uncertain = true
+		decision.Reason = fmt.Sprintf("margin %.4f is below %.4f", decision.Margin, p.MinMargin)
+	}
+
+	if decision.Uncertain {
+		decision.Class = UNCERTAIN_CLASS
+	}
+	return decision
+}
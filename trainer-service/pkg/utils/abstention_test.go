@@ -0,0 +1,136 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+var testRanking = []Prediction{
+	{Class: "class1", Probability: 0.6},
+	{Class: "class2", Probability: 0.3},
+	{Class: "class3", Probability: 0.1},
+}
+
+func TestDecideConfident(t *testing.T) {
+	decision := AbstentionPolicy{MinProbability: 0.5, MinMargin: 0.2}.Decide(testRanking)
+	if decision.Uncertain || decision.Class != "class1" {
+		t.Errorf("Expected confident class1 decision, got %+v", decision)
+	}
+	if decision.Margin < 0.3-1e-9 || decision.Margin > 0.3+1e-9 {
+		t.Errorf("Expected margin 0.3, got %f", decision.Margin)
+	}
+}
+
+func TestDecideLowProbability(t *testing.T) {
+	decision := AbstentionPolicy{MinProbability: 0.7}.Decide(testRanking)
+	if !decision.Uncertain || decision.Class != UNCERTAIN_CLASS {
+		t.Errorf("Expected uncertain decision, got %+v", decision)
+	}
+	if decision.Candidate != "class1" {
+		t.Errorf("Expected class1 candidate, got %s", decision.Candidate)
+	}
+}
+
+func TestDecideLowMargin(t *testing.T) {
+	decision := AbstentionPolicy{MinMargin: 0.4}.Decide(testRanking)
+	if !decision.Uncertain || decision.Class != UNCERTAIN_CLASS {
+		t.Errorf("Expected uncertain decision, got %+v", decision)
+	}
+}
+
+func TestDecideClassThreshold(t *testing.T) {
+	policy := AbstentionPolicy{MinProbability: 0.9, ClassThresholds: map[string]float64{"class1": 0.5}}
+	if decision := policy.Decide(testRanking); decision.Uncertain {
+		t.Errorf("Expected class threshold to override min probability, got %+v", decision)
+	}
+
+	policy = AbstentionPolicy{ClassThresholds: map[string]float64{"class1": 0.8}}
+	if decision := policy.Decide(testRanking); !decision.Uncertain {
+		t.Errorf("Expected class threshold to be applied, got %+v", decision)
+	}
+}
+
+func TestDecideZeroPolicy(t *testing.T) {
+	decision := AbstentionPolicy{}.Decide(testRanking)
+	if decision.Uncertain {
+		t.Errorf("Expected zero policy to never abstain, got %+v", decision)
+	}
+}
+
+func TestDecideNoPredictions(t *testing.T) {
+	decision := AbstentionPolicy{}.Decide(nil)
+	if !decision.Uncertain {
+		t.Errorf("Expected uncertain decision without predictions, got %+v", decision)
+	}
+}
+
+func TestReadAbstentionPolicy(t *testing.T) {
+	err := ioutil.WriteFile("test.json", []byte(`{"min_probability": 0.6, "min_margin": 0.1, "class_thresholds": {"Mortgage": 0.8}}`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test file: %v", err)
+	}
+	defer os.Remove("test.json")
+
+	result, err := ReadAbstentionPolicy("test.json")
+	if err != nil {
+		t.Fatalf("Error reading abstention policy: %v", err)
+	}
+	expected := AbstentionPolicy{MinProbability: 0.6, MinMargin: 0.1, ClassThresholds: map[string]float64{"Mortgage": 0.8}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+}
+
+func TestReadAbstentionPolicyInvalidJSON(t *testing.T) {
+	err := ioutil.WriteFile("test.json", []byte(`{"min_probability": `), 0666)
+	if err != nil {
+		t.Errorf("Error creating test file: %v", err)
+	}
+	defer os.Remove("test.json")
+
+	if _, err := ReadAbstentionPolicy("test.json"); err == nil {
+		t.Errorf("Expected error reading invalid JSON")
+	}
+}
+
+func TestLoadAbstentionPolicyWithEnv(t *testing.T) {
+	err := ioutil.WriteFile("test.json", []byte(`{"min_probability": 0.6, "min_margin": 0.1, "class_thresholds": {"Mortgage": 0.8}}`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test file: %v", err)
+	}
+	defer os.Remove("test.json")
+
+	os.Setenv("MIN_PROBABILITY", "0.75")
+	defer os.Unsetenv("MIN_PROBABILITY")
+
+	result, err := LoadAbstentionPolicy("test.json")
+	if err != nil {
+		t.Fatalf("Error loading abstention policy: %v", err)
+	}
+	expected := AbstentionPolicy{MinProbability: 0.75, MinMargin: 0.1, ClassThresholds: map[string]float64{"Mortgage": 0.8}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected MIN_PROBABILITY to override the policy file: got %v, want %v", result, expected)
+	}
+
+	os.Setenv("MIN_MARGIN", "margin")
+	defer os.Unsetenv("MIN_MARGIN")
+	if _, err := LoadAbstentionPolicy("test.json"); err == nil {
+		t.Errorf("Expected error for MIN_MARGIN that is not a number")
+	}
+}
+
+func TestLoadAbstentionPolicyWithoutFile(t *testing.T) {
+	os.Setenv("MIN_MARGIN", "0.2")
+	defer os.Unsetenv("MIN_MARGIN")
+
+	result, err := LoadAbstentionPolicy("")
+	if err != nil {
+		t.Fatalf("Error loading abstention policy: %v", err)
+	}
+	expected := AbstentionPolicy{MinMargin: 0.2}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+}
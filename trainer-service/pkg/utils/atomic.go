@@ -0,0 +1,57 @@
+package util
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Returned when a model file is not a readable bundle or does not match its checksums.
+var ErrCorruptModel = errors.New("corrupt model")
+
+// Writes the file through a temporary file in the same directory that is synced and renamed
+// over the destination, so readers see either the old or the new file, never a partial one.
+func writeFileAtomic(fileDir string, perm os.FileMode, write func(w io.Writer) error) error {
+	dir := filepath.Dir(fileDir)
+	if err := os.MkdirAll(dir, 0777); err != nil {
+		return err
+	}
+
+	file, err := ioutil.TempFile(dir, "."+filepath.Base(fileDir)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	// Removes the temporary file when writing fails, it is already renamed otherwise.
+	defer os.Remove(file.Name())
+
+	if err := write(file); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(file.Name(), perm); err != nil {
+		return err
+	}
+	if err := os.Rename(file.Name(), fileDir); err != nil {
+		return err
+	}
+	return syncDir(dir)
+}
+
+// Persists renames in the directory.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,94 @@
+package util
+
+import (
+	"errors"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWriteModelBundleWritesChecksums(t *testing.T) {
+	err := WriteModelBundle("test_dir/test_model.gob", newTestBundle())
+	defer os.RemoveAll("test_dir")
+	if err != nil {
+		t.Fatalf("Error writing model bundle: %v", err)
+	}
+
+	bundle, err := ReadModelBundle("test_dir/test_model.gob")
+	if err != nil {
+		t.Fatalf("Error reading model bundle: %v", err)
+	}
+	for _, name := range []string{BUNDLE_CLASSIFIER_FILE, BUNDLE_STOP_WORDS_FILE, BUNDLE_TOKENIZER_FILE} {
+		if len(bundle.Manifest.Checksums[name]) != 64 {
+			t.Errorf("Expected checksum of %s in the manifest, got %v", name, bundle.Manifest.Checksums)
+		}
+	}
+	entries, _ := ioutil.ReadDir("test_dir")
+	if len(entries) != 1 {
+		t.Errorf("Expected only the model to be left, got %d files", len(entries))
+	}
+}
+
+func TestWriteModelFileKeepsOldFileOnError(t *testing.T) {
+	err := WriteModelBundle("test_dir/test_model.gob", newTestBundle())
+	defer os.RemoveAll("test_dir")
+	if err != nil {
+		t.Fatalf("Error writing model bundle: %v", err)
+	}
+
+	errWriting := errors.New("disk full")
+	err = writeFileAtomic("test_dir/test_model.gob", 0666, func(w io.Writer) error {
+		w.Write([]byte("partial"))
+		return errWriting
+	})
+	if !errors.Is(err, errWriting) {
+		t.Errorf("Expected writer error to be returned, got %v", err)
+	}
+
+	if _, err := ReadModelBundle("test_dir/test_model.gob"); err != nil {
+		t.Errorf("Expected the previous model to be kept, got %v", err)
+	}
+	entries, _ := ioutil.ReadDir("test_dir")
+	for _, entry := range entries {
+		if strings.Contains(entry.Name(), ".tmp-") {
+			t.Errorf("Expected temporary file %s to be removed", entry.Name())
+		}
+	}
+}
+
+func TestReadModelBundleCorrupt(t *testing.T) {
+	err := WriteModelBundle("test_dir/model.zip", newTestBundle())
+	defer os.RemoveAll("test_dir")
+	if err != nil {
+		t.Fatalf("Error writing model bundle: %v", err)
+	}
+
+	if err := os.Truncate("test_dir/model.zip", 100); err != nil {
+		t.Fatalf("Error truncating model bundle: %v", err)
+	}
+	if _, err := ReadModelBundle("test_dir/model.zip"); !errors.Is(err, ErrCorruptModel) {
+		t.Errorf("Expected ErrCorruptModel, got %v", err)
+	}
+}
+
+func TestReadModelBundleChecksums(t *testing.T) {
+	defer os.RemoveAll("test_dir")
+
+	modifications := map[string]func(manifest map[string]interface{}){
+		"missing checksums": func(manifest map[string]interface{}) { delete(manifest, "checksums") },
+		"missing checksum": func(manifest map[string]interface{}) {
+			delete(manifest["checksums"].(map[string]interface{}), BUNDLE_TOKENIZER_FILE)
+		},
+		"other checksum": func(manifest map[string]interface{}) {
+			manifest["checksums"].(map[string]interface{})[BUNDLE_CLASSIFIER_FILE] = strings.Repeat("0", 64)
+		},
+	}
+	for name, fn := range modifications {
+		writeModifiedBundle(t, fn)
+		if _, err := ReadModelBundle("test_dir/modified.zip"); !errors.Is(err, ErrCorruptModel) {
+			t.Errorf("Expected ErrCorruptModel for %s, got %v", name, err)
+		}
+	}
+}
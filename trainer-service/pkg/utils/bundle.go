@@ -0,0 +1,318 @@
+package util
+
+import (
+	"archive/zip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"time"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+	"github.com/navossoc/bayesian"
+)
+
+const (
+	// Version of the bundle layout. Bump it on changes older readers can not load.
+	MODEL_SCHEMA_VERSION = 1
+
+	MODEL_KIND_FLAT         = "flat"
+	MODEL_KIND_HIERARCHICAL = "hierarchical"
+
+	BUNDLE_MANIFEST_FILE   = "manifest.json"
+	BUNDLE_CLASSIFIER_FILE = "classifier.gob"
+	BUNDLE_HIERARCHY_FILE  = "hierarchy.gob"
+	BUNDLE_STOP_WORDS_FILE = "stop_words.json"
+	BUNDLE_TOKENIZER_FILE  = "tokenizer.json"
+)
+
+// Returned when a model bundle can not be loaded by this version of the code.
+var ErrIncompatibleModel = errors.New("incompatible model")
+
+// Describes what is in a model bundle and how it was trained.
+type ModelManifest struct {
+	SchemaVersion int       `json:"schema_version"`
+	Kind          string    `json:"kind"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Classes in the classifier order, root classes of hierarchical models.
+	Classes []string `json:"classes"`
+	// Number of tickets every class was trained with.
+	Tickets map[string]int `json:"tickets,omitempty"`
+	// Mapping of ticket fields onto the text the model learned from.
+	Mapping     models.FieldMapping `json:"mapping"`
+	Fingerprint ModelFingerprint    `json:"fingerprint"`
+	// Evaluation report of the model, when one was attached at training.
+	Metrics json.RawMessage `json:"metrics,omitempty"`
+	// Hex SHA-256 checksums of the other entries of the bundle by entry name.
+	Checksums map[string]string `json:"checksums"`
+}
+
+// A trained model with everything needed to predict with it, stored as a single zip file.
+type ModelBundle struct {
+	Manifest ModelManifest
+	// Set for flat models.
+	Classifier *bayesian.Classifier
+	// Set for hierarchical models.
+	Hierarchy *HierarchicalModel
+	StopWords models.StopWordsFile
+	Tokenizer TokenizerConfig
+}
+
+// Returns the attached metrics when they are an evaluation report of a held-out split.
+func (m ModelManifest) EvaluationReport() (*EvaluationReport, bool) {
+	var report EvaluationReport
+	if len(m.Metrics) == 0 || json.Unmarshal(m.Metrics, &report) != nil || report.Samples == 0 {
+		return nil, false
+	}
+	return &report, true
+}
+
+func (b *ModelBundle) StopWordLists() *StopWordLists {
+	return NewStopWordListsFromFile(b.StopWords)
+}
+
+// Returns the predictor of a flat model, failing when its tokenizer config is not valid.
+func (b *ModelBundle) Predictor() (*Predictor, error) {
+	tokenizer, err := NewTokenizer(b.Tokenizer)
+	if err != nil {
+		return nil, err
+	}
+	return NewPredictor(b.Classifier, b.StopWordLists().Global(), tokenizer), nil
+}
+
+// Returns the predictor of a hierarchical model, failing when its tokenizer config is not valid.
+func (b *ModelBundle) HierarchicalPredictor() (*HierarchicalPredictor, error) {
+	return NewHierarchicalPredictor(b.Hierarchy, b.StopWordLists().Global())
+}
+
+// Builds the text to classify from ticket fields with the mapping the model was trained with.
+func (b *ModelBundle) Text(record models.Record) string {
+	return MappedText(b.Manifest.Mapping, record)
+}
+
+// Writes the bundle atomically, replacing an existing one. Schema version, kind, classes,
+// creation time and checksums of the manifest are set from the bundle.
+func WriteModelBundle(bundleFileDir string, bundle *ModelBundle) error {
+	manifest := bundle.Manifest
+	manifest.SchemaVersion = MODEL_SCHEMA_VERSION
+	if manifest.CreatedAt.IsZero() {
+		manifest.CreatedAt = time.Now().UTC()
+	}
+
+	var writeClassifier func(archive *bundleWriter) error
+	switch {
+	case bundle.Classifier != nil:
+		manifest.Kind = MODEL_KIND_FLAT
+		manifest.Classes = classNames(bundle.Classifier.Classes)
+		writeClassifier = func(archive *bundleWriter) error {
+			return archive.writeFile(BUNDLE_CLASSIFIER_FILE, bundle.Classifier.WriteTo)
+		}
+	case bundle.Hierarchy != nil:
+		manifest.Kind = MODEL_KIND_HIERARCHICAL
+		manifest.Classes = classNames(bundle.Hierarchy.Root.Classes)
+		writeClassifier = func(archive *bundleWriter) error {
+			return archive.writeFile(BUNDLE_HIERARCHY_FILE, func(w io.Writer) error {
+				return writeHierarchicalModel(w, bundle.Hierarchy)
+			})
+		}
+	default:
+		return errors.New("model bundle has no classifier")
+	}
+
+	err := writeFileAtomic(bundleFileDir, 0666, func(w io.Writer) error {
+		archive := &bundleWriter{archive: zip.NewWriter(w), checksums: make(map[string]string)}
+		if err := writeClassifier(archive); err != nil {
+			return err
+		}
+		if err := archive.writeJSON(BUNDLE_STOP_WORDS_FILE, bundle.StopWords); err != nil {
+			return err
+		}
+		if err := archive.writeJSON(BUNDLE_TOKENIZER_FILE, bundle.Tokenizer); err != nil {
+			return err
+		}
+		// The manifest is written last, with checksums of all entries before it.
+		manifest.Checksums = archive.checksums
+		if err := archive.writeJSON(BUNDLE_MANIFEST_FILE, manifest); err != nil {
+			return err
+		}
+		return archive.archive.Close()
+	})
+	if err != nil {
+		return err
+	}
+	bundle.Manifest = manifest
+	return nil
+}
+
+// Writes entries of a bundle, collecting their checksums.
+type bundleWriter struct {
+	archive   *zip.Writer
+	checksums map[string]string
+}
+
+func (b *bundleWriter) writeFile(name string, write func(w io.Writer) error) error {
+	w, err := b.archive.Create(name)
+	if err != nil {
+		return err
+	}
+	hash := sha256.New()
+	if err := write(io.MultiWriter(w, hash)); err != nil {
+		return err
+	}
+	b.checksums[name] = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
+
+func (b *bundleWriter) writeJSON(name string, value interface{}) error {
+	return b.writeFile(name, func(w io.Writer) error {
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(value)
+	})
+}
+
+// Reads a model bundle, failing with ErrCorruptModel when it is not a readable zip file or its
+// entries do not match checksums of the manifest, and with ErrIncompatibleModel when the bundle
+// was written by a newer schema or its contents do not match the manifest.
+func ReadModelBundle(bundleFileDir string) (*ModelBundle, error) {
+	archive, err := zip.OpenReader(bundleFileDir)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s is not a model bundle, retrain the model: %v", ErrCorruptModel, bundleFileDir, err)
+	}
+	defer archive.Close()
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+
+	bundle := &ModelBundle{}
+	if err := readBundleJSON(files, BUNDLE_MANIFEST_FILE, &bundle.Manifest); err != nil {
+		return nil, fmt.Errorf("%s is not a model bundle, retrain the model: %w", bundleFileDir, err)
+	}
+	manifest := bundle.Manifest
+	if manifest.SchemaVersion < 1 || manifest.SchemaVersion > MODEL_SCHEMA_VERSION {
+		return nil, fmt.Errorf("%w: schema version %d, supported versions are 1 to %d", ErrIncompatibleModel, manifest.SchemaVersion, MODEL_SCHEMA_VERSION)
+	}
+	for _, f := range archive.File {
+		if f.Name == BUNDLE_MANIFEST_FILE {
+			continue
+		}
+		if err := verifyBundleFile(f, manifest.Checksums[f.Name]); err != nil {
+			return nil, fmt.Errorf("model %s: %w", bundleFileDir, err)
+		}
+	}
+
+	if err := readBundleJSON(files, BUNDLE_STOP_WORDS_FILE, &bundle.StopWords); err != nil {
+		return nil, err
+	}
+	if err := readBundleJSON(files, BUNDLE_TOKENIZER_FILE, &bundle.Tokenizer); err != nil {
+		return nil, err
+	}
+	if err := bundle.Tokenizer.Validate(); err != nil {
+		return nil, fmt.Errorf("%w: tokenizer config: %v", ErrIncompatibleModel, err)
+	}
+
+	var classes []bayesian.Class
+	switch manifest.Kind {
+	case MODEL_KIND_FLAT:
+		err = readBundleFile(files, BUNDLE_CLASSIFIER_FILE, func(r io.Reader) (err error) {
+			bundle.Classifier, err = bayesian.NewClassifierFromReader(r)
+			return err
+		})
+		if err == nil {
+			classes = bundle.Classifier.Classes
+		}
+	case MODEL_KIND_HIERARCHICAL:
+		err = readBundleFile(files, BUNDLE_HIERARCHY_FILE, func(r io.Reader) (err error) {
+			bundle.Hierarchy, err = readHierarchicalModel(r)
+			return err
+		})
+		if err == nil {
+			bundle.Hierarchy.Tokenizer = bundle.Tokenizer
+			classes = bundle.Hierarchy.Root.Classes
+		}
+	default:
+		return nil, fmt.Errorf("%w: unknown model kind %q", ErrIncompatibleModel, manifest.Kind)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !reflect.DeepEqual(classNames(classes), manifest.Classes) {
+		return nil, fmt.Errorf("%w: classifier classes %v do not match manifest classes %v", ErrIncompatibleModel, classes, manifest.Classes)
+	}
+	return bundle, nil
+}
+
+// Reads only the manifest of a model bundle, without loading the classifier.
+func ReadModelManifest(bundleFileDir string) (ModelManifest, error) {
+	var manifest ModelManifest
+	archive, err := zip.OpenReader(bundleFileDir)
+	if err != nil {
+		return manifest, fmt.Errorf("%s is not a model bundle: %w", bundleFileDir, err)
+	}
+	defer archive.Close()
+
+	files := make(map[string]*zip.File, len(archive.File))
+	for _, f := range archive.File {
+		files[f.Name] = f
+	}
+	err = readBundleJSON(files, BUNDLE_MANIFEST_FILE, &manifest)
+	return manifest, err
+}
+
+// Reads the entry to the end, failing with ErrCorruptModel when it has no checksum or does not match it.
+func verifyBundleFile(f *zip.File, checksum string) error {
+	if checksum == "" {
+		return fmt.Errorf("%w: manifest has no checksum of %s", ErrCorruptModel, f.Name)
+	}
+	r, err := f.Open()
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrCorruptModel, err)
+	}
+	defer r.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, r); err != nil {
+		return fmt.Errorf("%w: can not read %s: %v", ErrCorruptModel, f.Name, err)
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != checksum {
+		return fmt.Errorf("%w: checksum of %s is %s, expected %s", ErrCorruptModel, f.Name, actual, checksum)
+	}
+	return nil
+}
+
+func readBundleFile(files map[string]*zip.File, name string, read func(r io.Reader) error) error {
+	f, ok := files[name]
+	if !ok {
+		return fmt.Errorf("%w: %s is missing in the bundle", ErrIncompatibleModel, name)
+	}
+	r, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err := read(r); err != nil {
+		return fmt.Errorf("can not read %s of the bundle: %w", name, err)
+	}
+	return nil
+}
+
+func readBundleJSON(files map[string]*zip.File, name string, value interface{}) error {
+	return readBundleFile(files, name, func(r io.Reader) error {
+		return json.NewDecoder(r).Decode(value)
+	})
+}
+
+func classNames(classes []bayesian.Class) []string {
+	names := make([]string, len(classes))
+	for i, class := range classes {
+		names[i] = string(class)
+	}
+	return names
+}
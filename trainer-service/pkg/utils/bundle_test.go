@@ -0,0 +1,183 @@
+package util
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"reflect"
+	"testing"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+	"github.com/navossoc/bayesian"
+)
+
+var classes = []bayesian.Class{bayesian.Class("class1"), bayesian.Class("class2")}
+var classifier = bayesian.NewClassifier(classes...)
+
+func newTestBundle() *ModelBundle {
+	return &ModelBundle{
+		Manifest: ModelManifest{
+			Mapping: DefaultFieldMapping(),
+			Metrics: json.RawMessage(`{"accuracy":0.9}`),
+		},
+		Classifier: classifier,
+		StopWords:  models.StopWordsFile{Global: []string{"the", "my"}},
+		Tokenizer:  TokenizerConfig{NGrams: 2},
+	}
+}
+
+func TestModelBundleRoundTrip(t *testing.T) {
+	err := WriteModelBundle("test_dir/model.zip", newTestBundle())
+	defer os.RemoveAll("test_dir")
+	if err != nil {
+		t.Fatalf("Error writing model bundle: %v", err)
+	}
+
+	bundle, err := ReadModelBundle("test_dir/model.zip")
+	if err != nil {
+		t.Fatalf("Error reading model bundle: %v", err)
+	}
+	manifest := bundle.Manifest
+	if manifest.SchemaVersion != MODEL_SCHEMA_VERSION || manifest.Kind != MODEL_KIND_FLAT || manifest.CreatedAt.IsZero() {
+		t.Errorf("Unexpected manifest: %+v", manifest)
+	}
+	if !reflect.DeepEqual(manifest.Classes, []string{"class1", "class2"}) {
+		t.Errorf("Test case failed: got %v, want %v", manifest.Classes, []string{"class1", "class2"})
+	}
+	var metrics EvaluationReport
+	if err := json.Unmarshal(manifest.Metrics, &metrics); err != nil || metrics.Accuracy != 0.9 {
+		t.Errorf("Expected metrics to be stored, got %s", manifest.Metrics)
+	}
+	if !reflect.DeepEqual(manifest.Mapping, DefaultFieldMapping()) {
+		t.Errorf("Test case failed: got %+v, want %+v", manifest.Mapping, DefaultFieldMapping())
+	}
+	if !reflect.DeepEqual(bundle.Classifier.Classes, classifier.Classes) {
+		t.Errorf("Test case failed: got %v, want %v", bundle.Classifier.Classes, classifier.Classes)
+	}
+	if !reflect.DeepEqual(bundle.StopWords.Global, []string{"the", "my"}) || bundle.Tokenizer.NGrams != 2 {
+		t.Errorf("Expected stop words and tokenizer config to be stored, got %+v and %+v", bundle.StopWords, bundle.Tokenizer)
+	}
+
+	text := bundle.Text(models.Record{"issue": "late fee", "complaint_what_happened": "charged twice"})
+	if text != "late fee charged twice" {
+		t.Errorf("Test case failed: got %q, want %q", text, "late fee charged twice")
+	}
+}
+
+func TestHierarchicalModelBundle(t *testing.T) {
+	model := trainTestHierarchy(t)
+	err := WriteModelBundle("test_dir/model.zip", &ModelBundle{Hierarchy: model, Tokenizer: TokenizerConfig{NGrams: 2}})
+	defer os.RemoveAll("test_dir")
+	if err != nil {
+		t.Fatalf("Error writing model bundle: %v", err)
+	}
+
+	bundle, err := ReadModelBundle("test_dir/model.zip")
+	if err != nil {
+		t.Fatalf("Error reading model bundle: %v", err)
+	}
+	if bundle.Manifest.Kind != MODEL_KIND_HIERARCHICAL || bundle.Classifier != nil {
+		t.Errorf("Expected hierarchical model, got %+v", bundle.Manifest)
+	}
+	if !reflect.DeepEqual(bundle.Hierarchy.SingleChildren, model.SingleChildren) || bundle.Hierarchy.Tokenizer.NGrams != 2 {
+		t.Errorf("Expected hierarchy to be stored, got %+v", bundle.Hierarchy)
+	}
+	if !reflect.DeepEqual(bundle.Hierarchy.Root.Classes, model.Root.Classes) {
+		t.Errorf("Expected root classes %v, got %v", model.Root.Classes, bundle.Hierarchy.Root.Classes)
+	}
+	if !reflect.DeepEqual(bundle.Hierarchy.Children["Mortgage"].Classes, model.Children["Mortgage"].Classes) {
+		t.Errorf("Expected Mortgage child classes %v, got %v", model.Children["Mortgage"].Classes, bundle.Hierarchy.Children["Mortgage"].Classes)
+	}
+}
+
+func TestReadModelBundleRawModel(t *testing.T) {
+	os.MkdirAll("test_dir", 0777)
+	err := classifier.WriteToFile("test_dir/test_model.gob")
+	defer os.RemoveAll("test_dir")
+	if err != nil {
+		t.Fatalf("Error writing model to file: %v", err)
+	}
+
+	if _, err := ReadModelBundle("test_dir/test_model.gob"); err == nil {
+		t.Errorf("Expected error reading a raw model as a bundle")
+	}
+}
+
+// Writes a bundle with the manifest changed by fn.
+func writeModifiedBundle(t *testing.T, fn func(manifest map[string]interface{})) {
+	if err := WriteModelBundle("test_dir/model.zip", newTestBundle()); err != nil {
+		t.Fatalf("Error writing model bundle: %v", err)
+	}
+	original, err := zip.OpenReader("test_dir/model.zip")
+	if err != nil {
+		t.Fatalf("Error opening model bundle: %v", err)
+	}
+	defer original.Close()
+
+	file, err := os.Create("test_dir/modified.zip")
+	if err != nil {
+		t.Fatalf("Error creating bundle: %v", err)
+	}
+	defer file.Close()
+	archive := zip.NewWriter(file)
+	defer archive.Close()
+
+	for _, f := range original.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatalf("Error reading %s: %v", f.Name, err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatalf("Error reading %s: %v", f.Name, err)
+		}
+
+		if f.Name == BUNDLE_MANIFEST_FILE {
+			manifest := make(map[string]interface{})
+			if err := json.Unmarshal(data, &manifest); err != nil {
+				t.Fatalf("Error parsing manifest: %v", err)
+			}
+			fn(manifest)
+			data, _ = json.Marshal(manifest)
+		}
+
+		w, err := archive.Create(f.Name)
+		if err != nil {
+			t.Fatalf("Error writing %s: %v", f.Name, err)
+		}
+		w.Write(data)
+	}
+}
+
+func TestReadModelBundleIncompatible(t *testing.T) {
+	defer os.RemoveAll("test_dir")
+
+	modifications := map[string]func(manifest map[string]interface{}){
+		"newer schema":   func(manifest map[string]interface{}) { manifest["schema_version"] = MODEL_SCHEMA_VERSION + 1 },
+		"unknown kind":   func(manifest map[string]interface{}) { manifest["kind"] = "ensemble" },
+		"other classes":  func(manifest map[string]interface{}) { manifest["classes"] = []string{"class2", "class1"} },
+		"missing schema": func(manifest map[string]interface{}) { delete(manifest, "schema_version") },
+	}
+	for name, fn := range modifications {
+		writeModifiedBundle(t, fn)
+		if _, err := ReadModelBundle("test_dir/modified.zip"); !errors.Is(err, ErrIncompatibleModel) {
+			t.Errorf("Expected incompatible model error for %s, got %v", name, err)
+		}
+	}
+}
+
+func TestWriteModelBundleWithoutClassifier(t *testing.T) {
+	defer os.RemoveAll("test_dir")
+	if err := WriteModelBundle("test_dir/model.zip", &ModelBundle{}); err == nil {
+		t.Errorf("Expected error writing bundle without classifier")
+	}
+}
+
+func TestReadModelBundleMissingFile(t *testing.T) {
+	if _, err := ReadModelBundle("missing.zip"); err == nil {
+		t.Errorf("Expected error reading missing bundle")
+	}
+}
@@ -0,0 +1,142 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+)
+
+// Tokenizer config evaluated in a comparison.
+type TokenizerVariant struct {
+	Name   string          `json:"name"`
+	Config TokenizerConfig `json:"config"`
+}
+
+type TokenizerComparison struct {
+	TokenizerVariant
+	Report *EvaluationReport `json:"report"`
+	// Time spent tokenizing every text of the dataset once, and the resulting throughput.
+	TokenizeSeconds float64 `json:"tokenize_seconds"`
+	TextsPerSecond  float64 `json:"texts_per_second"`
+}
+
+// Results of tokenizer variants evaluated on the same held-out split.
+type TokenizerComparisonReport []TokenizerComparison
+
+// Creates variants of the base config from a spec like "term_frequency=binary,raw,log",
+// setting the JSON field of the config to every listed value.
+func ParseTokenizerVariants(base TokenizerConfig, spec string) ([]TokenizerVariant, error) {
+	field, values, ok := strings.Cut(spec, "=")
+	if !ok || field == "" || values == "" {
+		return nil, fmt.Errorf("tokenizer variants must look like field=value1,value2, got %q", spec)
+	}
+
+	var variants []TokenizerVariant
+	for _, value := range strings.Split(values, ",") {
+		config, err := withField(base, field, value)
+		if err != nil {
+			return nil, err
+		}
+		if err := config.Validate(); err != nil {
+			return nil, fmt.Errorf("%s=%s: %w", field, value, err)
+		}
+		variants = append(variants, TokenizerVariant{Name: fmt.Sprintf("%s=%s", field, value), Config: config})
+	}
+	return variants, nil
+}
+
+// Returns a copy of the config with the JSON field set to the value. Values which are
+// not valid JSON are set as strings.
+func withField(config TokenizerConfig, field string, value string) (TokenizerConfig, error) {
+	encoded, err := json.Marshal(config)
+	if err != nil {
+		return config, err
+	}
+
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(encoded, &fields); err != nil {
+		return config, err
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal([]byte(value), &decoded); err != nil {
+		decoded = value
+	}
+	fields[field] = decoded
+
+	encoded, err = json.Marshal(fields)
+	if err != nil {
+		return config, err
+	}
+
+	var result TokenizerConfig
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&result); err != nil {
+		return config, fmt.Errorf("can not set tokenizer %s to %s: %w", field, value, err)
+	}
+	return result, nil
+}
+
+// Trains and evaluates a classifier with every tokenizer variant on the same held-out split,
+// see HoldoutEvaluate.
+func CompareTokenizers(dataset Dataset, stopWords *StopWordLists, variants []TokenizerVariant, testRatio float64, seed int64) (TokenizerComparisonReport, error) {
+	report := make(TokenizerComparisonReport, 0, len(variants))
+	for _, variant := range variants {
+		tokenizer, err := NewTokenizer(variant.Config)
+		if err != nil {
+			return nil, fmt.Errorf("tokenizer variant %s: %w", variant.Name, err)
+		}
+		evaluation, err := HoldoutEvaluate(dataset, stopWords, tokenizer, testRatio, seed)
+		if err != nil {
+			return nil, fmt.Errorf("tokenizer variant %s: %w", variant.Name, err)
+		}
+		comparison := TokenizerComparison{TokenizerVariant: variant, Report: evaluation}
+		if comparison.TokenizeSeconds, comparison.TextsPerSecond, err = tokenizationThroughput(tokenizer, dataset, stopWords.Global()); err != nil {
+			return nil, err
+		}
+		report = append(report, comparison)
+	}
+	return report, nil
+}
+
+// Measures how long it takes to tokenize every text of the dataset with the tokenizer,
+// not counting the time spent reading the dataset.
+func tokenizationThroughput(tokenizer *Tokenizer, dataset Dataset, stopWords map[string]struct{}) (float64, float64, error) {
+	texts := 0
+	var elapsed time.Duration
+	err := dataset.Stream(func(c models.TrainingCase) error {
+		start := time.Now()
+		tokenizer.Tokenize([]string{c.Text}, stopWords)
+		elapsed += time.Since(start)
+		texts++
+		return nil
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	seconds := elapsed.Seconds()
+	if seconds == 0 {
+		return 0, 0, nil
+	}
+	return seconds, float64(texts) / seconds, nil
+}
+
+// Formats metrics of every variant as a human readable table.
+func (r TokenizerComparisonReport) String() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(w, "Variant\tAccuracy\tMacro F1\tMicro F1\tTexts/s")
+	for _, c := range r {
+		fmt.Fprintf(w, "%s\t%.4f\t%.4f\t%.4f\t%.0f\n", c.Name, c.Report.Accuracy, c.Report.MacroF1, c.Report.MicroF1, c.TextsPerSecond)
+	}
+
+	w.Flush()
+	return buf.String()
+}
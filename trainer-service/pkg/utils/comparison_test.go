@@ -0,0 +1,94 @@
+package util
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseTokenizerVariants(t *testing.T) {
+	base := TokenizerConfig{NGrams: 2, MaxTermFrequency: 3}
+	variants, err := ParseTokenizerVariants(base, "term_frequency=binary,raw,cap")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(variants) != 3 {
+		t.Fatalf("Expected 3 variants, got %v", variants)
+	}
+
+	capped := variants[2]
+	expected := TokenizerConfig{NGrams: 2, TermFrequency: TERM_FREQUENCY_CAP, MaxTermFrequency: 3}
+	if capped.Name != "term_frequency=cap" || !reflect.DeepEqual(capped.Config, expected) {
+		t.Errorf("Test case failed: got %+v, want %+v", capped, expected)
+	}
+}
+
+func TestParseTokenizerVariantsNumbers(t *testing.T) {
+	variants, err := ParseTokenizerVariants(TokenizerConfig{}, "ngrams=1,2,3")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for i, v := range variants {
+		if v.Config.NGrams != i+1 {
+			t.Errorf("Expected %d ngrams, got %+v", i+1, v.Config)
+		}
+	}
+}
+
+func TestParseTokenizerVariantsMorphology(t *testing.T) {
+	variants, err := ParseTokenizerVariants(TokenizerConfig{}, "morphology=lemmatize,stem")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(variants) != 2 || variants[1].Config.Morphology != STAGE_STEM {
+		t.Errorf("Expected lemmatizer and stemmer variants, got %+v", variants)
+	}
+}
+
+func TestParseTokenizerVariantsInvalid(t *testing.T) {
+	for _, spec := range []string{"", "ngrams", "unknown=1", "ngrams=9", "term_frequency=cap"} {
+		if _, err := ParseTokenizerVariants(TokenizerConfig{}, spec); err == nil {
+			t.Errorf("Expected error parsing %q", spec)
+		}
+	}
+}
+
+func TestCompareTokenizers(t *testing.T) {
+	writeTestDataset(t, "test_data.json", map[string]string{
+		"class1": "late fee on my card",
+		"class2": "escrow payment on mortgage",
+	}, 20)
+	defer os.Remove("test_data.json")
+
+	variants := []TokenizerVariant{
+		{Name: "binary", Config: TokenizerConfig{}},
+		{Name: "raw", Config: TokenizerConfig{TermFrequency: TERM_FREQUENCY_RAW}},
+	}
+	report, err := CompareTokenizers(NewDataset("test_data.json"), NewStopWordLists(map[string]struct{}{}, nil), variants, 0.25, 42)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(report) != 2 {
+		t.Fatalf("Expected a result for every variant, got %v", report)
+	}
+	for _, c := range report {
+		if c.Report.Accuracy != 1 {
+			t.Errorf("Expected %s variant to classify test cases correctly, got accuracy %f", c.Name, c.Report.Accuracy)
+		}
+		if c.TokenizeSeconds < 0 || c.TextsPerSecond < 0 {
+			t.Errorf("Expected %s variant throughput to be measured, got %f s and %f texts/s", c.Name, c.TokenizeSeconds, c.TextsPerSecond)
+		}
+	}
+	if text := report.String(); !strings.Contains(text, "binary") || !strings.Contains(text, "Texts/s") {
+		t.Errorf("Expected report to contain variants and metrics, got %s", text)
+	}
+}
+
+func TestCompareTokenizersInvalidVariant(t *testing.T) {
+	variants := []TokenizerVariant{{Name: "cap", Config: TokenizerConfig{TermFrequency: TERM_FREQUENCY_CAP}}}
+	if _, err := CompareTokenizers(NewDataset("test_data.json"), NewStopWordLists(map[string]struct{}{}, nil), variants, 0.25, 42); err == nil {
+		t.Errorf("Expected error comparing invalid tokenizer variant")
+	}
+}
@@ -0,0 +1,154 @@
+package util
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"text/tabwriter"
+)
+
+type MetricSummary struct {
+	Mean   float64 `json:"mean"`
+	StdDev float64 `json:"std_dev"`
+}
+
+type ClassMetricsSummary struct {
+	Precision MetricSummary `json:"precision"`
+	Recall    MetricSummary `json:"recall"`
+	F1        MetricSummary `json:"f1"`
+	Support   MetricSummary `json:"support"`
+}
+
+type CrossValidationReport struct {
+	Folds       int                            `json:"folds"`
+	Accuracy    MetricSummary                  `json:"accuracy"`
+	MacroF1     MetricSummary                  `json:"macro_f1"`
+	MicroF1     MetricSummary                  `json:"micro_f1"`
+	Classes     []string                       `json:"classes"`
+	PerClass    map[string]ClassMetricsSummary `json:"per_class"`
+	FoldReports []*EvaluationReport            `json:"fold_reports"`
+}
+
+// Trains and evaluates a model for every fold and summarizes metrics across folds. Every ticket
+// is assigned to a random fold drawn in file order from a generator seeded with seed, and the
+// dataset is streamed twice for every fold instead of being loaded into memory.
+func CrossValidate(dataset Dataset, stopWords *StopWordLists, config TokenizerConfig, k int, seed int64) (*CrossValidationReport, error) {
+	if k < 2 {
+		return nil, errors.New("number of folds must be at least 2")
+	}
+	tokenizer, err := NewTokenizer(config)
+	if err != nil {
+		return nil, err
+	}
+
+	folds := randomFolds(k, seed)
+	reports := make([]*EvaluationReport, k)
+	for i := range reports {
+		log.Printf("Training fold %d of %d", i+1, k)
+		if reports[i], err = evaluateFold(dataset, stopWords, tokenizer, folds, i); err != nil {
+			return nil, fmt.Errorf("fold %d: %w", i+1, err)
+		}
+	}
+
+	return summarizeFolds(reports), nil
+}
+
+func summarizeFolds(reports []*EvaluationReport) *CrossValidationReport {
+	summary := &CrossValidationReport{
+		Folds:       len(reports),
+		PerClass:    make(map[string]ClassMetricsSummary),
+		FoldReports: reports,
+	}
+
+	var accuracy, macroF1, microF1 []float64
+	classes := make(map[string]struct{})
+	for _, r := range reports {
+		accuracy = append(accuracy, r.Accuracy)
+		macroF1 = append(macroF1, r.MacroF1)
+		microF1 = append(microF1, r.MicroF1)
+		for _, class := range r.Classes {
+			classes[class] = struct{}{}
+		}
+	}
+	summary.Accuracy = summarize(accuracy)
+	summary.MacroF1 = summarize(macroF1)
+	summary.MicroF1 = summarize(microF1)
+
+	for class := range classes {
+		summary.Classes = append(summary.Classes, class)
+	}
+	sort.Strings(summary.Classes)
+
+	for _, class := range summary.Classes {
+		var precision, recall, f1, support []float64
+		for _, r := range reports {
+			m, ok := r.PerClass[class]
+			// A fold without cases of the class tells nothing about how well it is recognized.
+			if !ok || m.Support == 0 {
+				continue
+			}
+			precision = append(precision, m.Precision)
+			recall = append(recall, m.Recall)
+			f1 = append(f1, m.F1)
+			support = append(support, float64(m.Support))
+		}
+		summary.PerClass[class] = ClassMetricsSummary{
+			Precision: summarize(precision),
+			Recall:    summarize(recall),
+			F1:        summarize(f1),
+			Support:   summarize(support),
+		}
+	}
+	return summary
+}
+
+// Returns mean and sample standard deviation of the values.
+func summarize(values []float64) MetricSummary {
+	if len(values) == 0 {
+		return MetricSummary{}
+	}
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+
+	if len(values) == 1 {
+		return MetricSummary{Mean: mean}
+	}
+
+	squares := 0.0
+	for _, v := range values {
+		squares += (v - mean) * (v - mean)
+	}
+	return MetricSummary{Mean: mean, StdDev: math.Sqrt(squares / float64(len(values)-1))}
+}
+
+// Formats the report as a human readable table.
+func (r *CrossValidationReport) String() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Folds:\t%d\n", r.Folds)
+	fmt.Fprintf(w, "Accuracy:\t%s\n", r.Accuracy)
+	fmt.Fprintf(w, "Macro F1:\t%s\n", r.MacroF1)
+	fmt.Fprintf(w, "Micro F1:\t%s\n", r.MicroF1)
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "Class\tPrecision\tRecall\tF1\tSupport")
+	for _, class := range r.Classes {
+		m := r.PerClass[class]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.1f\n", class, m.Precision, m.Recall, m.F1, m.Support.Mean)
+	}
+
+	w.Flush()
+	return buf.String()
+}
+
+func (m MetricSummary) String() string {
+	return fmt.Sprintf("%.4f ± %.4f", m.Mean, m.StdDev)
+}
@@ -0,0 +1,64 @@
+package util
+
+import (
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCrossValidateInvalidK(t *testing.T) {
+	_, err := CrossValidate(NewDataset("test_data.json"), NewStopWordLists(map[string]struct{}{}, nil), TokenizerConfig{}, 1, 1)
+	if err == nil {
+		t.Errorf("Expected error for less than 2 folds")
+	}
+}
+
+func TestCrossValidate(t *testing.T) {
+	writeTestDataset(t, "test_data.json", map[string]string{
+		"class1": "mortgage escrow loan",
+		"class2": "card charge fee",
+	}, 20)
+	defer os.Remove("test_data.json")
+
+	report, err := CrossValidate(NewDataset("test_data.json"), NewStopWordLists(map[string]struct{}{}, nil), TokenizerConfig{}, 2, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if report.Folds != 2 || len(report.FoldReports) != 2 {
+		t.Errorf("Expected 2 folds, got %d", report.Folds)
+	}
+	if report.Accuracy.Mean != 1 {
+		t.Errorf("Expected perfect accuracy on separable data, got %v", report.Accuracy)
+	}
+	if report.PerClass["class1"].Support.Mean != 10 {
+		t.Errorf("Expected every class1 case to be tested once, got %v", report.PerClass["class1"].Support)
+	}
+	if !strings.Contains(report.String(), "class2") {
+		t.Errorf("Expected report to mention class2, got %s", report.String())
+	}
+
+	again, err := CrossValidate(NewDataset("test_data.json"), NewStopWordLists(map[string]struct{}{}, nil), TokenizerConfig{}, 2, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(again.FoldReports, report.FoldReports) {
+		t.Errorf("Expected the same folds for the same seed")
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	result := summarize([]float64{1, 2, 3, 4})
+	if result.Mean != 2.5 {
+		t.Errorf("Expected mean 2.5, got %f", result.Mean)
+	}
+	if math.Abs(result.StdDev-math.Sqrt(5.0/3.0)) > 1e-9 {
+		t.Errorf("Expected sample standard deviation %f, got %f", math.Sqrt(5.0/3.0), result.StdDev)
+	}
+
+	empty := summarize(nil)
+	if empty.Mean != 0 || empty.StdDev != 0 {
+		t.Errorf("Expected zero summary for no values, got %v", empty)
+	}
+}
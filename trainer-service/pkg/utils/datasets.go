@@ -0,0 +1,204 @@
+package util
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+)
+
+// Supported dataset formats.
+const (
+	FORMAT_JSON  = "json"
+	FORMAT_JSONL = "jsonl"
+	FORMAT_CSV   = "csv"
+)
+
+// Field of a record with the id of the document in Elasticsearch exports.
+const RECORD_ID_FIELD = "_id"
+
+// Reads records of a dataset one at a time, calling fn for every record.
+type DatasetReader interface {
+	Read(r io.Reader, fn func(models.Record) error) error
+}
+
+// A training data file, its format and the mapping of its fields onto support cases.
+type Dataset struct {
+	Path string
+	// Format of the file, detected from the file extension when empty.
+	Format  string
+	Mapping models.FieldMapping
+}
+
+// Returns a dataset of the file with format detected from its extension
+// and the default field mapping of the Kaggle complaints data.
+func NewDataset(path string) Dataset {
+	return Dataset{Path: path, Mapping: DefaultFieldMapping()}
+}
+
+// Returns the reader for the format: json for Elasticsearch exports with a _source
+// wrapper, jsonl for JSON Lines and csv for CSV files with a header row.
+func NewDatasetReader(format string) (DatasetReader, error) {
+	switch format {
+	case FORMAT_JSON:
+		return elasticReader{}, nil
+	case FORMAT_JSONL:
+		return jsonLinesReader{}, nil
+	case FORMAT_CSV:
+		return csvReader{}, nil
+	}
+	return nil, fmt.Errorf("unknown dataset format %q", format)
+}
+
+// Detects dataset format from the file extension.
+func DetectFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FORMAT_JSON, nil
+	case ".jsonl", ".ndjson":
+		return FORMAT_JSONL, nil
+	case ".csv":
+		return FORMAT_CSV, nil
+	}
+	return "", fmt.Errorf("can not detect format of %s, set it explicitly", path)
+}
+
+// Returns the format of the dataset, detected from the file extension when it is not set.
+func (d Dataset) ResolvedFormat() (string, error) {
+	if d.Format != "" {
+		return d.Format, nil
+	}
+	return DetectFormat(d.Path)
+}
+
+// Reads support cases of the dataset one at a time. Records are turned into support
+// cases with the field mapping and those it filters out are skipped.
+func (d Dataset) Stream(fn func(models.TrainingCase) error) error {
+	format, err := d.ResolvedFormat()
+	if err != nil {
+		return err
+	}
+
+	reader, err := NewDatasetReader(format)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(d.Path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	return reader.Read(bufio.NewReader(file), func(record models.Record) error {
+		if c, ok := applyFieldMapping(d.Mapping, record); ok {
+			return fn(c)
+		}
+		return nil
+	})
+}
+
+type elasticReader struct{}
+
+type elasticRecord struct {
+	ID     interface{}            `json:"_id"`
+	Source map[string]interface{} `json:"_source"`
+}
+
+func (elasticReader) Read(r io.Reader, fn func(models.Record) error) error {
+	return decodeJSONArray(r, func(item elasticRecord) error {
+		record := RecordFromJSON(item.Source)
+		// The document id is kept with the fields unless the document has an _id field of its own.
+		if _, ok := record[RECORD_ID_FIELD]; !ok && item.ID != nil {
+			record[RECORD_ID_FIELD] = jsonValueText(item.ID)
+		}
+		return fn(record)
+	})
+}
+
+type jsonLinesReader struct{}
+
+func (jsonLinesReader) Read(r io.Reader, fn func(models.Record) error) error {
+	decoder := json.NewDecoder(r)
+	for line := 1; ; line++ {
+		var item map[string]interface{}
+		err := decoder.Decode(&item)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("not a valid json line %d: %w", line, err)
+		}
+		if err := fn(RecordFromJSON(item)); err != nil {
+			return err
+		}
+	}
+}
+
+type csvReader struct{}
+
+func (csvReader) Read(r io.Reader, fn func(models.Record) error) error {
+	reader := csv.NewReader(r)
+	reader.ReuseRecord = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return fmt.Errorf("can not read csv header: %w", err)
+	}
+	columns := append([]string{}, header...)
+
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		record := make(models.Record, len(columns))
+		for i, column := range columns {
+			record[column] = row[i]
+		}
+		if err := fn(record); err != nil {
+			return err
+		}
+	}
+}
+
+// Converts decoded JSON object to a record, formatting non string values as text.
+func RecordFromJSON(item map[string]interface{}) models.Record {
+	record := make(models.Record, len(item))
+	for key, value := range item {
+		record[key] = jsonValueText(value)
+	}
+	return record
+}
+
+func jsonValueText(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	case []interface{}:
+		texts := make([]string, 0, len(v))
+		for _, item := range v {
+			texts = append(texts, jsonValueText(item))
+		}
+		return strings.Join(texts, " ")
+	}
+	bytes, _ := json.Marshal(value)
+	return string(bytes)
+}
@@ -0,0 +1,174 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+)
+
+func streamAll(t *testing.T, dataset Dataset) []models.TrainingCase {
+	var result []models.TrainingCase
+	err := dataset.Stream(func(c models.TrainingCase) error {
+		result = append(result, c)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Error streaming dataset: %v", err)
+	}
+	return result
+}
+
+func TestDetectFormat(t *testing.T) {
+	formats := map[string]string{
+		"data/complaints.json": FORMAT_JSON,
+		"tickets.JSONL":        FORMAT_JSONL,
+		"tickets.ndjson":       FORMAT_JSONL,
+		"export/tickets.csv":   FORMAT_CSV,
+	}
+	for path, expected := range formats {
+		result, err := DetectFormat(path)
+		if err != nil || result != expected {
+			t.Errorf("Test case failed for %s: got %s (%v), want %s", path, result, err, expected)
+		}
+	}
+
+	if _, err := DetectFormat("tickets.xml"); err == nil {
+		t.Errorf("Expected error detecting format of unknown extension")
+	}
+}
+
+func TestNewDatasetReaderUnknownFormat(t *testing.T) {
+	if _, err := NewDatasetReader("xml"); err == nil {
+		t.Errorf("Expected error for unknown format")
+	}
+}
+
+func TestStreamElasticDataset(t *testing.T) {
+	err := ioutil.WriteFile("test.json", []byte(`[{"_id": "1", "_source": {"issue": "title1", "complaint_what_happened": "description1", "product": "class1", "tags": null}}]`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test file: %v", err)
+	}
+	defer os.Remove("test.json")
+
+	result := streamAll(t, NewDataset("test.json"))
+	expected := []models.TrainingCase{{Class: "class1", Text: "title1 description1"}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+}
+
+func TestElasticReaderKeepsDocumentID(t *testing.T) {
+	data := `[{"_id": "a1", "_source": {"issue": "title1"}}, {"_id": 2, "_source": {"issue": "title2"}}, {"_source": {"issue": "title3"}}]`
+	var ids []string
+	err := elasticReader{}.Read(strings.NewReader(data), func(record models.Record) error {
+		ids = append(ids, record[RECORD_ID_FIELD])
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(ids, []string{"a1", "2", ""}) {
+		t.Errorf("Test case failed: got %v, want %v", ids, []string{"a1", "2", ""})
+	}
+}
+
+func TestStreamJSONLinesDataset(t *testing.T) {
+	data := `{"category": "class1", "subject": "title1", "body": "description1", "priority": 2}
+{"category": "class2", "subject": "title2", "body": "description2", "priority": 1}
+`
+	err := ioutil.WriteFile("test.jsonl", []byte(data), 0666)
+	if err != nil {
+		t.Errorf("Error creating test file: %v", err)
+	}
+	defer os.Remove("test.jsonl")
+
+	dataset := Dataset{Path: "test.jsonl", Mapping: models.FieldMapping{Label: "category", Text: []models.TextField{{Field: "subject"}, {Field: "body"}}}}
+	result := streamAll(t, dataset)
+	expected := []models.TrainingCase{
+		{Class: "class1", Text: "title1 description1"},
+		{Class: "class2", Text: "title2 description2"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+}
+
+func TestStreamJSONLinesDatasetInvalidLine(t *testing.T) {
+	err := ioutil.WriteFile("test.jsonl", []byte("{\"category\": \"class1\"}\n{\"category\": "), 0666)
+	if err != nil {
+		t.Errorf("Error creating test file: %v", err)
+	}
+	defer os.Remove("test.jsonl")
+
+	err = NewDataset("test.jsonl").Stream(func(c models.TrainingCase) error { return nil })
+	if err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("Expected error pointing to line 2, got %v", err)
+	}
+}
+
+func TestStreamCSVDataset(t *testing.T) {
+	data := "id,category,subject,body\n1,class1,title1,\"description, with comma\"\n2,class2,title2,description2\n"
+	err := ioutil.WriteFile("test.csv", []byte(data), 0666)
+	if err != nil {
+		t.Errorf("Error creating test file: %v", err)
+	}
+	defer os.Remove("test.csv")
+
+	dataset := Dataset{Path: "test.csv", Mapping: models.FieldMapping{Label: "category", Text: []models.TextField{{Field: "subject"}, {Field: "body"}}}}
+	result := streamAll(t, dataset)
+	expected := []models.TrainingCase{
+		{Class: "class1", Text: "title1 description, with comma"},
+		{Class: "class2", Text: "title2 description2"},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+}
+
+func TestStreamCSVDatasetWithExplicitFormat(t *testing.T) {
+	err := ioutil.WriteFile("test.txt", []byte("product,issue\nclass1,title1\n"), 0666)
+	if err != nil {
+		t.Errorf("Error creating test file: %v", err)
+	}
+	defer os.Remove("test.txt")
+
+	dataset := NewDataset("test.txt")
+	dataset.Format = FORMAT_CSV
+	result := streamAll(t, dataset)
+	expected := []models.TrainingCase{{Class: "class1", Text: "title1 "}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+}
+
+func TestStreamCSVDatasetMalformedRow(t *testing.T) {
+	err := ioutil.WriteFile("test.csv", []byte("product,issue\nclass1,title1,extra\n"), 0666)
+	if err != nil {
+		t.Errorf("Error creating test file: %v", err)
+	}
+	defer os.Remove("test.csv")
+
+	err = NewDataset("test.csv").Stream(func(c models.TrainingCase) error { return nil })
+	if err == nil {
+		t.Errorf("Expected error reading row with wrong number of columns")
+	}
+}
+
+func TestJSONValueText(t *testing.T) {
+	values := map[string]interface{}{
+		"":                    nil,
+		"text":                "text",
+		"12.5":                12.5,
+		"true":                true,
+		"older servicemember": []interface{}{"older", "servicemember"},
+	}
+	for expected, value := range values {
+		if result := jsonValueText(value); result != expected {
+			t.Errorf("Test case failed: got %s, want %s", result, expected)
+		}
+	}
+}
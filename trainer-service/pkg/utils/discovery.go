@@ -0,0 +1,131 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+)
+
+// Word of the corpus proposed as a stop word, with statistics for review.
+type StopWordCandidate struct {
+	Word string `json:"word"`
+	// Share of tickets with the word.
+	DocumentFrequency float64 `json:"document_frequency"`
+	Documents         int     `json:"documents"`
+	// Entropy of the word's distribution over classes, normalized to [0, 1].
+	// 1 means the word is equally frequent in tickets of every class.
+	ClassEntropy   float64        `json:"class_entropy"`
+	ClassDocuments map[string]int `json:"class_documents"`
+}
+
+// Thresholds a word must reach to be proposed as a stop word.
+type StopWordDiscovery struct {
+	MinDocumentFrequency float64
+	MinClassEntropy      float64
+}
+
+// Analyzes the corpus and proposes words, which are frequent in tickets and carry
+// no class signal. Known stop words are skipped. Candidates are sorted by document
+// frequency, the most frequent first.
+func (d StopWordDiscovery) Discover(dataset Dataset, known map[string]struct{}) ([]StopWordCandidate, error) {
+	documents := 0
+	classDocuments := make(map[string]int)
+	wordDocuments := make(map[string]map[string]int)
+
+	err := dataset.Stream(func(c models.TrainingCase) error {
+		documents++
+		classDocuments[c.Class]++
+
+		seen := make(map[string]struct{})
+		for _, word := range words(c.Text) {
+			if _, ok := seen[word]; ok {
+				continue
+			}
+			seen[word] = struct{}{}
+			if _, ok := known[word]; ok {
+				continue
+			}
+			if wordDocuments[word] == nil {
+				wordDocuments[word] = make(map[string]int)
+			}
+			wordDocuments[word][c.Class]++
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if len(classDocuments) < 2 {
+		return nil, fmt.Errorf("at least 2 classes are required to find words without class signal, found %d", len(classDocuments))
+	}
+
+	candidates := make([]StopWordCandidate, 0)
+	for word, perClass := range wordDocuments {
+		count := 0
+		for _, n := range perClass {
+			count += n
+		}
+
+		candidate := StopWordCandidate{
+			Word:              word,
+			DocumentFrequency: ratio(count, documents),
+			Documents:         count,
+			ClassEntropy:      classEntropy(perClass, classDocuments),
+			ClassDocuments:    perClass,
+		}
+		if candidate.DocumentFrequency >= d.MinDocumentFrequency && candidate.ClassEntropy >= d.MinClassEntropy {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Documents != candidates[j].Documents {
+			return candidates[i].Documents > candidates[j].Documents
+		}
+		return candidates[i].Word < candidates[j].Word
+	})
+	return candidates, nil
+}
+
+// Calculates normalized entropy of the word's class distribution. Word frequencies
+// are relative to the class sizes, so that large classes do not lower the entropy.
+func classEntropy(wordDocuments map[string]int, classDocuments map[string]int) float64 {
+	frequencies := make([]float64, 0, len(classDocuments))
+	var sum float64
+	for class, total := range classDocuments {
+		f := ratio(wordDocuments[class], total)
+		frequencies = append(frequencies, f)
+		sum += f
+	}
+	if sum == 0 {
+		return 0
+	}
+
+	var entropy float64
+	for _, f := range frequencies {
+		if f > 0 {
+			p := f / sum
+			entropy -= p * math.Log(p)
+		}
+	}
+	return entropy / math.Log(float64(len(classDocuments)))
+}
+
+// Writes stop words as a JSON array, the format of the stop words file.
+func WriteStopWordsToFile(stopWordsDir string, stopWords []string) error {
+	if err := os.MkdirAll(filepath.Dir(stopWordsDir), 0777); err != nil {
+		return err
+	}
+
+	bytes, err := json.MarshalIndent(stopWords, "", "    ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(stopWordsDir, bytes, 0666)
+}
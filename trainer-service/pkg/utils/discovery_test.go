@@ -0,0 +1,107 @@
+package util
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func writeDiscoveryTestData(t *testing.T) {
+	err := ioutil.WriteFile("test_data.json", []byte(`[
+		{"_source": {"issue": "Escrow", "complaint_what_happened": "XXXX my mortgage escrow", "product": "Mortgage"}},
+		{"_source": {"issue": "Payment", "complaint_what_happened": "XXXX my mortgage payment", "product": "Mortgage"}},
+		{"_source": {"issue": "Fee", "complaint_what_happened": "XXXX card fee", "product": "Credit card"}},
+		{"_source": {"issue": "Statement", "complaint_what_happened": "XXXX my card statement", "product": "Credit card"}}
+	]`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test data file: %v", err)
+	}
+}
+
+func TestDiscoverStopWords(t *testing.T) {
+	writeDiscoveryTestData(t)
+	defer os.Remove("test_data.json")
+
+	discovery := StopWordDiscovery{MinDocumentFrequency: 0.5, MinClassEntropy: 0.9}
+	candidates, err := discovery.Discover(NewDataset("test_data.json"), map[string]struct{}{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var words []string
+	for _, c := range candidates {
+		words = append(words, c.Word)
+	}
+	expected := []string{"xxxx", "my"}
+	if !reflect.DeepEqual(words, expected) {
+		t.Errorf("Test case failed: got %v, want %v", words, expected)
+	}
+
+	xxxx := candidates[0]
+	if xxxx.Documents != 4 || xxxx.DocumentFrequency != 1 || math.Abs(xxxx.ClassEntropy-1) > 1e-9 {
+		t.Errorf("Unexpected statistics of xxxx: %+v", xxxx)
+	}
+}
+
+func TestDiscoverStopWordsSkipsKnown(t *testing.T) {
+	writeDiscoveryTestData(t)
+	defer os.Remove("test_data.json")
+
+	discovery := StopWordDiscovery{MinDocumentFrequency: 0.5, MinClassEntropy: 0.9}
+	candidates, err := discovery.Discover(NewDataset("test_data.json"), map[string]struct{}{"my": {}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(candidates) != 1 || candidates[0].Word != "xxxx" {
+		t.Errorf("Expected only xxxx to be proposed, got %v", candidates)
+	}
+}
+
+func TestDiscoverStopWordsSingleClass(t *testing.T) {
+	err := ioutil.WriteFile("test_data.json", []byte(`[{"_source": {"issue": "title1", "complaint_what_happened": "description1", "product": "class1"}}]`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test data file: %v", err)
+	}
+	defer os.Remove("test_data.json")
+
+	if _, err := (StopWordDiscovery{}).Discover(NewDataset("test_data.json"), nil); err == nil {
+		t.Errorf("Expected error discovering stop words of a single class")
+	}
+}
+
+func TestClassEntropy(t *testing.T) {
+	classDocuments := map[string]int{"class1": 10, "class2": 30}
+
+	if result := classEntropy(map[string]int{"class1": 5, "class2": 15}, classDocuments); math.Abs(result-1) > 1e-9 {
+		t.Errorf("Expected entropy 1 for a word equally frequent in both classes, got %f", result)
+	}
+	if result := classEntropy(map[string]int{"class1": 5}, classDocuments); result != 0 {
+		t.Errorf("Expected entropy 0 for a word of a single class, got %f", result)
+	}
+}
+
+func TestWriteStopWordsToFile(t *testing.T) {
+	err := WriteStopWordsToFile("test_dir/stop_words.json", []string{"xxxx", "my"})
+	defer os.RemoveAll("test_dir")
+	if err != nil {
+		t.Fatalf("Error writing stop words: %v", err)
+	}
+
+	result, err := ReadStopWords("test_dir/stop_words.json")
+	if err != nil {
+		t.Fatalf("Error reading stop words: %v", err)
+	}
+	expected := map[string]struct{}{"xxxx": {}, "my": {}}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+
+	bytes, _ := ioutil.ReadFile("test_dir/stop_words.json")
+	var words []string
+	if err := json.Unmarshal(bytes, &words); err != nil {
+		t.Errorf("Expected a JSON array of stop words, got %s", bytes)
+	}
+}
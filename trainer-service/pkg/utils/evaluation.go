@@ -0,0 +1,365 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+)
+
+type ClassMetrics struct {
+	Precision float64 `json:"precision"`
+	Recall    float64 `json:"recall"`
+	F1        float64 `json:"f1"`
+	Support   int     `json:"support"`
+}
+
+type EvaluationReport struct {
+	Samples  int                     `json:"samples"`
+	Accuracy float64                 `json:"accuracy"`
+	MacroF1  float64                 `json:"macro_f1"`
+	MicroF1  float64                 `json:"micro_f1"`
+	Classes  []string                `json:"classes"`
+	PerClass map[string]ClassMetrics `json:"per_class"`
+	// Rows are actual classes and columns are predicted classes, both in Classes order.
+	ConfusionMatrix [][]int `json:"confusion_matrix"`
+	// Coverage and accuracy of predictions kept by minimum probability and minimum margin thresholds.
+	ProbabilityCurve []CoveragePoint `json:"probability_curve,omitempty"`
+	MarginCurve      []CoveragePoint `json:"margin_curve,omitempty"`
+}
+
+type CoveragePoint struct {
+	Threshold float64 `json:"threshold"`
+	// Share of test cases with predictions kept by the threshold.
+	Coverage float64 `json:"coverage"`
+	// Accuracy of the kept predictions.
+	Accuracy float64 `json:"accuracy"`
+}
+
+// Confidence of a single test prediction and whether it was correct.
+type scoredPrediction struct {
+	decision Decision
+	correct  bool
+}
+
+const (
+	COVERAGE_CURVE_STEP = 0.05
+)
+
+// Trains a flat model on the dataset without held-out tickets and evaluates it on the held-out
+// ones. Every ticket is held out with probability testRatio, drawn in file order from a generator
+// seeded with seed, so the dataset is streamed twice instead of being loaded into memory.
+func HoldoutEvaluate(dataset Dataset, stopWords *StopWordLists, tokenizer *Tokenizer, testRatio float64, seed int64) (*EvaluationReport, error) {
+	return evaluateFold(dataset, stopWords, tokenizer, holdoutFolds(testRatio, seed), 0)
+}
+
+// Like HoldoutEvaluate, but trains a hierarchical model and evaluates leaf classes of the most
+// likely paths, so a ticket is classified correctly only when classes of both levels are.
+func HierarchicalHoldoutEvaluate(dataset Dataset, stopWords *StopWordLists, tokenizer *Tokenizer, testRatio float64, seed int64) (*EvaluationReport, error) {
+	if dataset.Mapping.ChildLabel == "" {
+		return nil, errors.New("child label field is required to evaluate a hierarchical model")
+	}
+
+	folds := holdoutFolds(testRatio, seed)
+	model, _, err := hierarchicalModelFromStream(foldStream(dataset, folds, 0, false), stopWords, tokenizer)
+	if err != nil {
+		return nil, err
+	}
+	predictor, err := NewHierarchicalPredictor(model, stopWords.Global())
+	if err != nil {
+		return nil, err
+	}
+
+	e := &evaluation{classes: predictor.LeafClasses(), rank: predictor.RankLeaves, counts: make(map[string]map[string]int)}
+	err = foldStream(dataset, folds, 0, true)(func(c models.TrainingCase) error {
+		e.add(leafClass(c), c.Text)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e.report(), nil
+}
+
+// Returns a fold for every ticket streamed in file order. Every call starts a new assignment
+// from the same state, so every stream of a dataset assigns its tickets to the same folds.
+type foldAssignment func() func() int
+
+// Assigns held-out tickets to fold 0 and the rest to fold 1.
+func holdoutFolds(testRatio float64, seed int64) foldAssignment {
+	return func() func() int {
+		rnd := rand.New(rand.NewSource(seed))
+		return func() int {
+			if rnd.Float64() < testRatio {
+				return 0
+			}
+			return 1
+		}
+	}
+}
+
+// Assigns every ticket to one of k folds at random.
+func randomFolds(k int, seed int64) foldAssignment {
+	return func() func() int {
+		rnd := rand.New(rand.NewSource(seed))
+		return func() int {
+			return rnd.Intn(k)
+		}
+	}
+}
+
+// Streams tickets of the dataset in the fold when inFold is true, and the other tickets otherwise.
+func foldStream(dataset Dataset, folds foldAssignment, fold int, inFold bool) func(fn func(models.TrainingCase) error) error {
+	return func(fn func(models.TrainingCase) error) error {
+		next := folds()
+		return dataset.Stream(func(c models.TrainingCase) error {
+			if (next() == fold) == inFold {
+				return fn(c)
+			}
+			return nil
+		})
+	}
+}
+
+// Trains a flat model on tickets outside the fold and evaluates it on tickets of the fold.
+func evaluateFold(dataset Dataset, stopWords *StopWordLists, tokenizer *Tokenizer, folds foldAssignment, fold int) (*EvaluationReport, error) {
+	vocabularies, _, err := streamVocabularies(foldStream(dataset, folds, fold, false), stopWords, tokenizer)
+	if err != nil {
+		return nil, err
+	}
+	classifier, err := classifierFromVocabularies(vocabularies, tokenizer)
+	if err != nil {
+		return nil, err
+	}
+
+	e := newEvaluation(NewPredictor(classifier, stopWords.Global(), tokenizer))
+	err = foldStream(dataset, folds, fold, true)(func(c models.TrainingCase) error {
+		e.add(c.Class, c.Text)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return e.report(), nil
+}
+
+// Counts of predicted classes of every actual class, collected one test case at a time.
+type evaluation struct {
+	// Classes the model can predict and the ranking of them for a text.
+	classes []string
+	rank    func(text string) []Prediction
+	counts  map[string]map[string]int
+	scored  []scoredPrediction
+}
+
+func newEvaluation(predictor *Predictor) *evaluation {
+	return &evaluation{classes: predictor.Classes(), rank: predictor.Predict, counts: make(map[string]map[string]int)}
+}
+
+func (e *evaluation) add(actual string, text string) {
+	decision := AbstentionPolicy{}.Decide(e.rank(text))
+	if e.counts[actual] == nil {
+		e.counts[actual] = make(map[string]int)
+	}
+	e.counts[actual][decision.Class]++
+	e.scored = append(e.scored, scoredPrediction{decision: decision, correct: decision.Class == actual})
+}
+
+func (e *evaluation) report() *EvaluationReport {
+	testClasses := make([]string, 0, len(e.counts))
+	for class := range e.counts {
+		testClasses = append(testClasses, class)
+	}
+	sort.Strings(testClasses)
+
+	classes := evaluationClasses(e.classes, testClasses)
+	index := make(map[string]int, len(classes))
+	for i, class := range classes {
+		index[class] = i
+	}
+
+	matrix := make([][]int, len(classes))
+	for i := range matrix {
+		matrix[i] = make([]int, len(classes))
+	}
+	for actual, predicted := range e.counts {
+		for class, count := range predicted {
+			matrix[index[actual]][index[class]] += count
+		}
+	}
+
+	report := reportFromConfusionMatrix(classes, matrix)
+	report.ProbabilityCurve = coverageCurve(e.scored, func(d Decision) float64 { return d.Probability })
+	report.MarginCurve = coverageCurve(e.scored, func(d Decision) float64 { return d.Margin })
+	return report
+}
+
+// Calculates coverage and accuracy of predictions for thresholds from 0 to 1 inclusive with
+// COVERAGE_CURVE_STEP, keeping predictions with the value at least the threshold.
+func coverageCurve(scored []scoredPrediction, value func(Decision) float64) []CoveragePoint {
+	steps := int(math.Round(1 / COVERAGE_CURVE_STEP))
+	curve := make([]CoveragePoint, 0, steps+1)
+
+	for i := 0; i <= steps; i++ {
+		threshold := float64(i) * COVERAGE_CURVE_STEP
+		kept, correct := 0, 0
+		for _, s := range scored {
+			if value(s.decision) >= threshold {
+				kept++
+				if s.correct {
+					correct++
+				}
+			}
+		}
+		curve = append(curve, CoveragePoint{
+			Threshold: threshold,
+			Coverage:  ratio(kept, len(scored)),
+			Accuracy:  ratio(correct, kept),
+		})
+	}
+	return curve
+}
+
+// Calculates per class and averaged metrics from the confusion matrix.
+func reportFromConfusionMatrix(classes []string, matrix [][]int) *EvaluationReport {
+	report := &EvaluationReport{
+		Classes:         classes,
+		PerClass:        make(map[string]ClassMetrics, len(classes)),
+		ConfusionMatrix: matrix,
+	}
+
+	var truePositives, falsePositives, falseNegatives int
+	var f1Sum float64
+	var supportedClasses int
+
+	for i, class := range classes {
+		tp := matrix[i][i]
+		actual, predicted := 0, 0
+		for j := range classes {
+			actual += matrix[i][j]
+			predicted += matrix[j][i]
+		}
+
+		metrics := ClassMetrics{
+			Precision: ratio(tp, predicted),
+			Recall:    ratio(tp, actual),
+			Support:   actual,
+		}
+		metrics.F1 = harmonicMean(metrics.Precision, metrics.Recall)
+		report.PerClass[class] = metrics
+
+		report.Samples += actual
+		truePositives += tp
+		falsePositives += predicted - tp
+		falseNegatives += actual - tp
+
+		if actual > 0 {
+			f1Sum += metrics.F1
+			supportedClasses++
+		}
+	}
+
+	report.Accuracy = ratio(truePositives, report.Samples)
+	report.MicroF1 = harmonicMean(ratio(truePositives, truePositives+falsePositives), ratio(truePositives, truePositives+falseNegatives))
+	if supportedClasses > 0 {
+		report.MacroF1 = f1Sum / float64(supportedClasses)
+	}
+	return report
+}
+
+// Formats the report as a human readable table.
+func (r *EvaluationReport) String() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "Samples:\t%d\n", r.Samples)
+	fmt.Fprintf(w, "Accuracy:\t%.4f\n", r.Accuracy)
+	fmt.Fprintf(w, "Macro F1:\t%.4f\n", r.MacroF1)
+	fmt.Fprintf(w, "Micro F1:\t%.4f\n", r.MicroF1)
+	fmt.Fprintln(w)
+
+	fmt.Fprintln(w, "#\tClass\tPrecision\tRecall\tF1\tSupport")
+	for i, class := range r.Classes {
+		m := r.PerClass[class]
+		fmt.Fprintf(w, "%d\t%s\t%.4f\t%.4f\t%.4f\t%d\n", i, class, m.Precision, m.Recall, m.F1, m.Support)
+	}
+	fmt.Fprintln(w)
+
+	fmt.Fprint(w, "Actual \\ Predicted")
+	for i := range r.Classes {
+		fmt.Fprintf(w, "\t%d", i)
+	}
+	fmt.Fprintln(w)
+	for i, row := range r.ConfusionMatrix {
+		fmt.Fprintf(w, "%d", i)
+		for _, count := range row {
+			fmt.Fprintf(w, "\t%d", count)
+		}
+		fmt.Fprintln(w)
+	}
+
+	if len(r.ProbabilityCurve) > 0 {
+		fmt.Fprintln(w)
+		fmt.Fprintln(w, "Threshold\tCoverage (probability)\tAccuracy (probability)\tCoverage (margin)\tAccuracy (margin)")
+		for i, p := range r.ProbabilityCurve {
+			m := r.MarginCurve[i]
+			fmt.Fprintf(w, "%.2f\t%.4f\t%.4f\t%.4f\t%.4f\n", p.Threshold, p.Coverage, p.Accuracy, m.Coverage, m.Accuracy)
+		}
+	}
+
+	w.Flush()
+	return buf.String()
+}
+
+// Writes the report as JSON, creating missing directories.
+func WriteReportToFile(reportFileDir string, report interface{}) error {
+	if err := os.MkdirAll(filepath.Dir(reportFileDir), 0777); err != nil {
+		return err
+	}
+
+	bytes, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(reportFileDir, bytes, 0666)
+}
+
+// Returns known classes of the model followed by classes found only in the test data.
+func evaluationClasses(modelClasses []string, testClasses []string) []string {
+	classes := append([]string{}, modelClasses...)
+	sort.Strings(classes)
+
+	known := make(map[string]struct{}, len(classes))
+	for _, class := range classes {
+		known[class] = struct{}{}
+	}
+	for _, class := range testClasses {
+		if _, ok := known[class]; !ok {
+			classes = append(classes, class)
+		}
+	}
+	return classes
+}
+
+func ratio(a int, b int) float64 {
+	if b == 0 {
+		return 0
+	}
+	return float64(a) / float64(b)
+}
+
+func harmonicMean(a float64, b float64) float64 {
+	if a+b == 0 {
+		return 0
+	}
+	return 2 * a * b / (a + b)
+}
@@ -0,0 +1,214 @@
+package util
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// Evaluates the predictor on the test cases of every class.
+func evaluateCases(predictor *Predictor, test map[string][]string) *EvaluationReport {
+	e := newEvaluation(predictor)
+	for class, texts := range test {
+		for _, text := range texts {
+			e.add(class, text)
+		}
+	}
+	return e.report()
+}
+
+// Writes the tickets of every class, one repeated text per class, as a dataset file.
+func writeTestDataset(t *testing.T, path string, texts map[string]string, repeat int) {
+	var records []string
+	for i := 0; i < repeat; i++ {
+		for _, class := range sortedTestKeys(texts) {
+			records = append(records, fmt.Sprintf(`{"_source": {"issue": %q, "complaint_what_happened": "", "product": %q}}`, texts[class], class))
+		}
+	}
+	if err := ioutil.WriteFile(path, []byte("["+strings.Join(records, ",")+"]"), 0666); err != nil {
+		t.Errorf("Error creating test data file: %v", err)
+	}
+}
+
+func sortedTestKeys(texts map[string]string) []string {
+	keys := make([]string, 0, len(texts))
+	for key := range texts {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func TestReportFromConfusionMatrix(t *testing.T) {
+	classes := []string{"class1", "class2"}
+	matrix := [][]int{
+		{3, 1},
+		{0, 4},
+	}
+	report := reportFromConfusionMatrix(classes, matrix)
+
+	if report.Samples != 8 {
+		t.Errorf("Expected 8 samples, got %d", report.Samples)
+	}
+	if report.Accuracy != 7.0/8.0 {
+		t.Errorf("Expected accuracy %f, got %f", 7.0/8.0, report.Accuracy)
+	}
+	if report.MicroF1 != report.Accuracy {
+		t.Errorf("Expected micro F1 %f to equal accuracy, got %f", report.Accuracy, report.MicroF1)
+	}
+
+	class1 := report.PerClass["class1"]
+	if class1.Precision != 1 || class1.Recall != 0.75 || class1.Support != 4 {
+		t.Errorf("Unexpected class1 metrics: %+v", class1)
+	}
+	class2 := report.PerClass["class2"]
+	if class2.Precision != 0.8 || class2.Recall != 1 {
+		t.Errorf("Unexpected class2 metrics: %+v", class2)
+	}
+
+	expectedMacroF1 := (harmonicMean(1, 0.75) + harmonicMean(0.8, 1)) / 2
+	if math.Abs(report.MacroF1-expectedMacroF1) > 1e-9 {
+		t.Errorf("Expected macro F1 %f, got %f", expectedMacroF1, report.MacroF1)
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	test := map[string][]string{
+		"class1": {"this is a text"},
+		"class2": {"this is another text"},
+		"class4": {"unknown class"},
+	}
+	report := evaluateCases(newTestPredictor(t), test)
+
+	expectedClasses := []string{"class1", "class2", "class3", "class4"}
+	if !reflect.DeepEqual(report.Classes, expectedClasses) {
+		t.Errorf("Test case failed: got %v, want %v", report.Classes, expectedClasses)
+	}
+	if report.Samples != 3 {
+		t.Errorf("Expected 3 samples, got %d", report.Samples)
+	}
+	if report.ConfusionMatrix[0][0] != 1 || report.ConfusionMatrix[1][1] != 1 {
+		t.Errorf("Expected class1 and class2 to be classified correctly, got %v", report.ConfusionMatrix)
+	}
+	if report.PerClass["class4"].Recall != 0 {
+		t.Errorf("Expected zero recall for class unknown to the model, got %f", report.PerClass["class4"].Recall)
+	}
+}
+
+func TestHoldoutEvaluate(t *testing.T) {
+	var records []string
+	for i := 0; i < 40; i++ {
+		records = append(records, `{"_source": {"issue": "escrow", "complaint_what_happened": "mortgage escrow payment", "product": "Mortgage"}}`)
+		records = append(records, `{"_source": {"issue": "late fee", "complaint_what_happened": "card late fee", "product": "Credit card"}}`)
+	}
+	err := ioutil.WriteFile("test_data.json", []byte("["+strings.Join(records, ",")+"]"), 0666)
+	if err != nil {
+		t.Errorf("Error creating test data file: %v", err)
+	}
+	defer os.Remove("test_data.json")
+
+	first, err := HoldoutEvaluate(NewDataset("test_data.json"), newTestStopWordLists(), newTestTokenizer(t, TokenizerConfig{}), 0.25, 42)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if first.Samples == 0 || first.Samples >= len(records) || first.Accuracy != 1 {
+		t.Errorf("Expected held-out tickets to be classified correctly, got %d samples with accuracy %f", first.Samples, first.Accuracy)
+	}
+
+	second, err := HoldoutEvaluate(NewDataset("test_data.json"), newTestStopWordLists(), newTestTokenizer(t, TokenizerConfig{}), 0.25, 42)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(first.ConfusionMatrix, second.ConfusionMatrix) {
+		t.Errorf("Expected the same split for the same seed, got %v and %v", first.ConfusionMatrix, second.ConfusionMatrix)
+	}
+}
+
+func TestHoldoutEvaluateSingleClass(t *testing.T) {
+	err := ioutil.WriteFile("test_data.json", []byte(`[{"_source": {"issue": "escrow", "complaint_what_happened": "", "product": "Mortgage"}}, {"_source": {"issue": "escrow payment", "complaint_what_happened": "", "product": "Mortgage"}}]`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test data file: %v", err)
+	}
+	defer os.Remove("test_data.json")
+
+	if _, err := HoldoutEvaluate(NewDataset("test_data.json"), newTestStopWordLists(), newTestTokenizer(t, TokenizerConfig{}), 0.25, 42); err == nil {
+		t.Errorf("Expected error evaluating a dataset with a single class")
+	}
+}
+
+func TestEvaluationReportString(t *testing.T) {
+	report := reportFromConfusionMatrix([]string{"class1", "class2"}, [][]int{{1, 0}, {0, 1}})
+	text := report.String()
+	for _, expected := range []string{"Accuracy:", "Macro F1:", "class1", "class2", "Actual \\ Predicted"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected report to contain %q, got %s", expected, text)
+		}
+	}
+}
+
+func TestWriteReportToFile(t *testing.T) {
+	report := reportFromConfusionMatrix([]string{"class1", "class2"}, [][]int{{1, 0}, {0, 1}})
+	err := WriteReportToFile("test_dir/report.json", report)
+	defer os.RemoveAll("test_dir")
+	if err != nil {
+		t.Fatalf("Error writing report to file: %v", err)
+	}
+
+	bytes, err := ioutil.ReadFile("test_dir/report.json")
+	if err != nil {
+		t.Fatalf("Error reading report file: %v", err)
+	}
+	var result EvaluationReport
+	if err := json.Unmarshal(bytes, &result); err != nil {
+		t.Fatalf("Error parsing report file: %v", err)
+	}
+	if !reflect.DeepEqual(&result, report) {
+		t.Errorf("Test case failed: got %v, want %v", result, report)
+	}
+}
+
+func TestEvaluateCoverageCurves(t *testing.T) {
+	test := map[string][]string{
+		"class1": {"this is a text"},
+		"class2": {"this is another text"},
+	}
+	report := evaluateCases(newTestPredictor(t), test)
+
+	if len(report.ProbabilityCurve) != 21 || len(report.MarginCurve) != 21 {
+		t.Fatalf("Expected 21 points in every curve, got %d and %d", len(report.ProbabilityCurve), len(report.MarginCurve))
+	}
+	first := report.ProbabilityCurve[0]
+	if first.Threshold != 0 || first.Coverage != 1 || first.Accuracy != report.Accuracy {
+		t.Errorf("Expected zero threshold to cover every prediction, got %+v", first)
+	}
+	for i := 1; i < len(report.ProbabilityCurve); i++ {
+		if report.ProbabilityCurve[i].Coverage > report.ProbabilityCurve[i-1].Coverage {
+			t.Errorf("Expected coverage to decrease with threshold, got %v", report.ProbabilityCurve)
+		}
+	}
+}
+
+func TestCoverageCurve(t *testing.T) {
+	scored := []scoredPrediction{
+		{decision: Decision{Probability: 0.9}, correct: true},
+		{decision: Decision{Probability: 0.7}, correct: true},
+		{decision: Decision{Probability: 0.4}, correct: false},
+		{decision: Decision{Probability: 0.3}, correct: false},
+	}
+	curve := coverageCurve(scored, func(d Decision) float64 { return d.Probability })
+
+	half := curve[10]
+	if half.Threshold != 0.5 || half.Coverage != 0.5 || half.Accuracy != 1 {
+		t.Errorf("Expected half of predictions kept with perfect accuracy at 0.5, got %+v", half)
+	}
+	last := curve[len(curve)-1]
+	if last.Threshold != 1 || last.Coverage != 0 || last.Accuracy != 0 {
+		t.Errorf("Expected no predictions kept at %.2f, got %+v", last.Threshold, last)
+	}
+}
@@ -0,0 +1,93 @@
+package util
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"runtime/debug"
+)
+
+// Version of the trainer recorded in model fingerprints. Set it at build time with
+// -ldflags "-X github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/utils.VERSION=1.0.0",
+// otherwise the VCS revision of the build is used.
+var VERSION = ""
+
+// Identifies what a model was trained from. Training is deterministic, so two runs
+// with the same fingerprint produce the same model.
+type ModelFingerprint struct {
+	// Hash of all the fields below.
+	Fingerprint string `json:"fingerprint"`
+	// MODEL_KIND_FLAT or MODEL_KIND_HIERARCHICAL.
+	Kind string `json:"kind"`
+	// Hashes of the training data file with its format and field mapping, and of the stop words file.
+	Data      string          `json:"data_sha256"`
+	StopWords string          `json:"stop_words_sha256"`
+	Tokenizer TokenizerConfig `json:"tokenizer"`
+	Version   string          `json:"version"`
+}
+
+// Returns the trainer version, VERSION or the VCS revision of the build, "devel" when neither is known.
+func TrainerVersion() string {
+	if VERSION != "" {
+		return VERSION
+	}
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			if setting.Key == "vcs.revision" {
+				return setting.Value
+			}
+		}
+	}
+	return "devel"
+}
+
+// Hashes the dataset, stop words and tokenizer config a model of the kind is trained from.
+// The format of the dataset is hashed as resolved, so a detected format and the same
+// format set explicitly give the same fingerprint.
+func NewModelFingerprint(dataset Dataset, stopWordsDir string, config TokenizerConfig, kind string) (ModelFingerprint, error) {
+	fingerprint := ModelFingerprint{Kind: kind, Tokenizer: config, Version: TrainerVersion()}
+
+	resolved, err := dataset.ResolvedFormat()
+	if err != nil {
+		return fingerprint, err
+	}
+	format, err := json.Marshal(struct {
+		Format  string      `json:"format"`
+		Mapping interface{} `json:"mapping"`
+	}{resolved, dataset.Mapping})
+	if err != nil {
+		return fingerprint, err
+	}
+	if fingerprint.Data, err = hashFile(dataset.Path, format); err != nil {
+		return fingerprint, err
+	}
+	if fingerprint.StopWords, err = hashFile(stopWordsDir, nil); err != nil {
+		return fingerprint, err
+	}
+
+	encoded, err := json.Marshal(fingerprint)
+	if err != nil {
+		return fingerprint, err
+	}
+	sum := sha256.Sum256(encoded)
+	fingerprint.Fingerprint = hex.EncodeToString(sum[:])
+	return fingerprint, nil
+}
+
+// Returns the hex SHA-256 of the file contents followed by extra bytes.
+func hashFile(fileName string, extra []byte) (string, error) {
+	file, err := os.Open(fileName)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	hash.Write(extra)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
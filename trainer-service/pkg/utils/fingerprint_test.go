@@ -0,0 +1,103 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/navossoc/bayesian"
+)
+
+func writeFingerprintTestFiles(t *testing.T) {
+	err := ioutil.WriteFile("test_data.json", []byte(`[{"_source": {"issue": "late fee", "complaint_what_happened": "card fee", "product": "class2"}}, {"_source": {"issue": "escrow", "complaint_what_happened": "mortgage", "product": "class1"}}, {"_source": {"issue": "report", "complaint_what_happened": "credit report", "product": "class3"}}]`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test data file: %v", err)
+	}
+	err = ioutil.WriteFile("stop_words.json", []byte(`["a", "b", "c"]`), 0666)
+	if err != nil {
+		t.Errorf("Error creating stop words file: %v", err)
+	}
+}
+
+func TestNewModelFingerprint(t *testing.T) {
+	writeFingerprintTestFiles(t)
+	defer os.Remove("test_data.json")
+	defer os.Remove("stop_words.json")
+
+	first, err := NewModelFingerprint(NewDataset("test_data.json"), "stop_words.json", TokenizerConfig{}, MODEL_KIND_FLAT)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	second, err := NewModelFingerprint(NewDataset("test_data.json"), "stop_words.json", TokenizerConfig{}, MODEL_KIND_FLAT)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if first.Fingerprint == "" || !reflect.DeepEqual(first, second) {
+		t.Errorf("Expected the same fingerprint for the same inputs, got %+v and %+v", first, second)
+	}
+
+	changed, err := NewModelFingerprint(NewDataset("test_data.json"), "stop_words.json", TokenizerConfig{NGrams: 2}, MODEL_KIND_FLAT)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if changed.Fingerprint == first.Fingerprint || changed.Data != first.Data {
+		t.Errorf("Expected only the tokenizer config to change the fingerprint, got %+v and %+v", first, changed)
+	}
+
+	dataset := NewDataset("test_data.json")
+	dataset.Mapping.Label = "issue"
+	remapped, err := NewModelFingerprint(dataset, "stop_words.json", TokenizerConfig{}, MODEL_KIND_FLAT)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if remapped.Data == first.Data {
+		t.Errorf("Expected field mapping to change the data hash")
+	}
+
+	dataset = NewDataset("test_data.json")
+	dataset.Format = FORMAT_JSON
+	explicit, err := NewModelFingerprint(dataset, "stop_words.json", TokenizerConfig{}, MODEL_KIND_FLAT)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if explicit.Fingerprint != first.Fingerprint {
+		t.Errorf("Expected explicit format to give the fingerprint of the detected one, got %+v and %+v", first, explicit)
+	}
+
+	hierarchical, err := NewModelFingerprint(NewDataset("test_data.json"), "stop_words.json", TokenizerConfig{}, MODEL_KIND_HIERARCHICAL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if hierarchical.Fingerprint == first.Fingerprint || hierarchical.Data != first.Data {
+		t.Errorf("Expected only the model kind to change the fingerprint, got %+v and %+v", first, hierarchical)
+	}
+}
+
+func TestNewModelFingerprintMissingData(t *testing.T) {
+	if _, err := NewModelFingerprint(NewDataset("missing.json"), "missing.json", TokenizerConfig{}, MODEL_KIND_FLAT); err == nil {
+		t.Errorf("Expected error fingerprinting missing data")
+	}
+}
+
+func TestTrainModelIsReproducible(t *testing.T) {
+	writeFingerprintTestFiles(t)
+	defer os.Remove("test_data.json")
+	defer os.Remove("stop_words.json")
+
+	var fingerprints []ModelFingerprint
+	for i := 0; i < 2; i++ {
+		bundle, err := TrainModelBundle(NewDataset("test_data.json"), "stop_words.json", newTestTokenizer(t, TokenizerConfig{}))
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		expected := []bayesian.Class{"class1", "class2", "class3"}
+		if !reflect.DeepEqual(bundle.Classifier.Classes, expected) {
+			t.Errorf("Test case failed: got %v, want %v", bundle.Classifier.Classes, expected)
+		}
+		fingerprints = append(fingerprints, bundle.Manifest.Fingerprint)
+	}
+	if !reflect.DeepEqual(fingerprints[0], fingerprints[1]) {
+		t.Errorf("Expected the same fingerprint on every run, got %+v and %+v", fingerprints[0], fingerprints[1])
+	}
+}
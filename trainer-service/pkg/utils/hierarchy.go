@@ -0,0 +1,289 @@
+package util
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"sort"
+	"strings"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+	"github.com/navossoc/bayesian"
+)
+
+// Two-level model: the root classifier predicts the class, and the child classifier
+// of that class predicts the child class within it.
+type HierarchicalModel struct {
+	Root     *bayesian.Classifier
+	Children map[string]*bayesian.Classifier
+	// Classes with a single child class have no child classifier, the child class is certain.
+	SingleChildren map[string]string
+	Tokenizer      TokenizerConfig
+}
+
+// Separates classes of a path in leaf classes, e.g. "Mortgage > FHA mortgage".
+const PATH_SEPARATOR = " > "
+
+type PathPrediction struct {
+	Path []string `json:"path"`
+	// Product of probabilities of the classes along the path.
+	Confidence float64      `json:"confidence"`
+	Levels     []Prediction `json:"levels"`
+}
+
+// Returns the leaf class of the path, its classes joined with PATH_SEPARATOR.
+func (p PathPrediction) Leaf() string {
+	return strings.Join(p.Path, PATH_SEPARATOR)
+}
+
+// Returns the leaf class of the ticket, the class followed by its child class when it has one.
+func leafClass(c models.TrainingCase) string {
+	if c.ChildClass == "" {
+		return c.Class
+	}
+	return c.Class + PATH_SEPARATOR + c.ChildClass
+}
+
+// Trains a new hierarchical model, bundled with the stop words, tokenizer config,
+// field mapping and fingerprint it was trained with.
+func TrainHierarchicalModelBundle(dataset Dataset, stopWordsDir string, tokenizer *Tokenizer) (*ModelBundle, error) {
+	stopWordsFile, err := ReadStopWordsFile(stopWordsDir)
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint, err := NewModelFingerprint(dataset, stopWordsDir, tokenizer.Config(), MODEL_KIND_HIERARCHICAL)
+	if err != nil {
+		return nil, err
+	}
+
+	model, vocabularies, err := trainHierarchicalModel(dataset, NewStopWordListsFromFile(stopWordsFile), tokenizer)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("Model fingerprint %s", fingerprint.Fingerprint)
+
+	return &ModelBundle{
+		Manifest:  ModelManifest{Mapping: dataset.Mapping, Fingerprint: fingerprint, Tickets: ticketCounts(vocabularies)},
+		Hierarchy: model,
+		StopWords: stopWordsFile,
+		Tokenizer: tokenizer.Config(),
+	}, nil
+}
+
+// Trains the root classifier on classes of the dataset and a child classifier for
+// every class on its child classes. The dataset mapping must have a child label.
+func TrainHierarchicalModel(dataset Dataset, stopWords *StopWordLists, tokenizer *Tokenizer) (*HierarchicalModel, error) {
+	model, _, err := trainHierarchicalModel(dataset, stopWords, tokenizer)
+	return model, err
+}
+
+// Trains the hierarchical model, returning it with vocabularies of its root classes.
+func trainHierarchicalModel(dataset Dataset, stopWords *StopWordLists, tokenizer *Tokenizer) (*HierarchicalModel, map[string]*classVocabulary, error) {
+	if dataset.Mapping.ChildLabel == "" {
+		return nil, nil, errors.New("child label field is required to train a hierarchical model")
+	}
+
+	return hierarchicalModelFromStream(dataset.Stream, stopWords, tokenizer)
+}
+
+// Trains the hierarchical model on streamed tickets, which must have child classes.
+func hierarchicalModelFromStream(stream func(fn func(models.TrainingCase) error) error, stopWords *StopWordLists, tokenizer *Tokenizer) (*HierarchicalModel, map[string]*classVocabulary, error) {
+	vocabularies, children, err := streamVocabularies(stream, stopWords, tokenizer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	root, err := classifierFromVocabularies(vocabularies, tokenizer)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	model := &HierarchicalModel{
+		Root:           root,
+		Children:       make(map[string]*bayesian.Classifier),
+		SingleChildren: make(map[string]string),
+		Tokenizer:      tokenizer.Config(),
+	}
+
+	for class, childVocabularies := range children {
+		if len(childVocabularies) == 1 {
+			for child := range childVocabularies {
+				model.SingleChildren[class] = child
+			}
+			continue
+		}
+
+		log.Printf("Training child classifier of '%s'", class)
+		child, err := classifierFromVocabularies(childVocabularies, tokenizer)
+		if err != nil {
+			return nil, nil, err
+		}
+		model.Children[class] = child
+	}
+	return model, vocabularies, nil
+}
+
+// Serialized form of the hierarchical model, with classifiers encoded by bayesian.
+type serializableHierarchy struct {
+	Root           []byte
+	Children       map[string][]byte
+	SingleChildren map[string]string
+	Tokenizer      TokenizerConfig
+}
+
+// Writes the root and all child classifiers together into the hierarchy entry of a bundle.
+func writeHierarchicalModel(w io.Writer, model *HierarchicalModel) error {
+	serializable := serializableHierarchy{
+		Children:       make(map[string][]byte, len(model.Children)),
+		SingleChildren: model.SingleChildren,
+		Tokenizer:      model.Tokenizer,
+	}
+
+	root, err := encodeClassifier(model.Root)
+	if err != nil {
+		return err
+	}
+	serializable.Root = root
+
+	for class, child := range model.Children {
+		encoded, err := encodeClassifier(child)
+		if err != nil {
+			return err
+		}
+		serializable.Children[class] = encoded
+	}
+
+	return gob.NewEncoder(w).Encode(serializable)
+}
+
+// Reads the hierarchy entry of a bundle.
+func readHierarchicalModel(r io.Reader) (*HierarchicalModel, error) {
+	var serializable serializableHierarchy
+	if err := gob.NewDecoder(r).Decode(&serializable); err != nil {
+		return nil, fmt.Errorf("not a hierarchical model: %w", err)
+	}
+
+	model := &HierarchicalModel{
+		Children:       make(map[string]*bayesian.Classifier, len(serializable.Children)),
+		SingleChildren: serializable.SingleChildren,
+		Tokenizer:      serializable.Tokenizer,
+	}
+	if model.SingleChildren == nil {
+		model.SingleChildren = make(map[string]string)
+	}
+
+	var err error
+	if model.Root, err = bayesian.NewClassifierFromReader(bytes.NewReader(serializable.Root)); err != nil {
+		return nil, err
+	}
+	for class, encoded := range serializable.Children {
+		child, err := bayesian.NewClassifierFromReader(bytes.NewReader(encoded))
+		if err != nil {
+			return nil, err
+		}
+		model.Children[class] = child
+	}
+	return model, nil
+}
+
+func encodeClassifier(classifier *bayesian.Classifier) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := classifier.WriteTo(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Predicts the path of classes from the root to the child class.
+type HierarchicalPredictor struct {
+	root           *Predictor
+	children       map[string]*Predictor
+	singleChildren map[string]string
+}
+
+// Creates predictors of all levels sharing a single tokenizer built from the model tokenizer config.
+func NewHierarchicalPredictor(model *HierarchicalModel, stopWords map[string]struct{}) (*HierarchicalPredictor, error) {
+	tokenizer, err := NewTokenizer(model.Tokenizer)
+	if err != nil {
+		return nil, err
+	}
+
+	children := make(map[string]*Predictor, len(model.Children))
+	for class, child := range model.Children {
+		children[class] = NewPredictor(child, stopWords, tokenizer)
+	}
+	return &HierarchicalPredictor{
+		root:           NewPredictor(model.Root, stopWords, tokenizer),
+		children:       children,
+		singleChildren: model.SingleChildren,
+	}, nil
+}
+
+// Returns the predictor of the root classes.
+func (p *HierarchicalPredictor) Root() *Predictor {
+	return p.root
+}
+
+// Returns leaf classes the predictor can choose from: every root class followed by each of
+// its child classes, or alone when it has none, sorted.
+func (p *HierarchicalPredictor) LeafClasses() []string {
+	var leaves []string
+	for _, class := range p.root.Classes() {
+		if child, ok := p.children[class]; ok {
+			for _, childClass := range child.Classes() {
+				leaves = append(leaves, class+PATH_SEPARATOR+childClass)
+			}
+		} else if single, ok := p.singleChildren[class]; ok {
+			leaves = append(leaves, class+PATH_SEPARATOR+single)
+		} else {
+			leaves = append(leaves, class)
+		}
+	}
+	sort.Strings(leaves)
+	return leaves
+}
+
+// Ranks the most likely path of every root class from the most to the least likely one,
+// as leaf classes with path confidences as probabilities.
+func (p *HierarchicalPredictor) RankLeaves(text string) []Prediction {
+	roots := p.root.Predict(text)
+	ranking := make([]Prediction, len(roots))
+	for i, top := range roots {
+		path := p.PathFrom(text, top)
+		ranking[i] = Prediction{Class: path.Leaf(), Probability: path.Confidence}
+	}
+	sort.SliceStable(ranking, func(i, j int) bool {
+		return ranking[i].Probability > ranking[j].Probability
+	})
+	return ranking
+}
+
+// Returns the most likely path. The path ends at the class when it has no child classes.
+func (p *HierarchicalPredictor) Predict(text string) PathPrediction {
+	return p.PathFrom(text, p.root.PredictTopK(text, 1)[0])
+}
+
+// Returns the path starting at the root class prediction top, so a ranking of root
+// classes made already is not repeated.
+func (p *HierarchicalPredictor) PathFrom(text string, top Prediction) PathPrediction {
+	prediction := PathPrediction{
+		Path:       []string{top.Class},
+		Confidence: top.Probability,
+		Levels:     []Prediction{top},
+	}
+
+	if child, ok := p.children[top.Class]; ok {
+		childTop := child.PredictTopK(text, 1)[0]
+		prediction.Path = append(prediction.Path, childTop.Class)
+		prediction.Confidence *= childTop.Probability
+		prediction.Levels = append(prediction.Levels, childTop)
+	} else if single, ok := p.singleChildren[top.Class]; ok {
+		prediction.Path = append(prediction.Path, single)
+		prediction.Levels = append(prediction.Levels, Prediction{Class: single, Probability: 1})
+	}
+	return prediction
+}
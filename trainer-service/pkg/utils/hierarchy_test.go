@@ -0,0 +1,125 @@
+package util
+
+import (
+	"io/ioutil"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const hierarchicalTestData = `[
+	{"_source": {"product": "Mortgage", "sub_product": "FHA mortgage", "issue": "fha", "complaint_what_happened": "mortgage fha insurance premium"}},
+	{"_source": {"product": "Mortgage", "sub_product": "FHA mortgage", "issue": "fha", "complaint_what_happened": "mortgage fha loan insurance"}},
+	{"_source": {"product": "Mortgage", "sub_product": "VA mortgage", "issue": "veteran", "complaint_what_happened": "mortgage veteran benefit"}},
+	{"_source": {"product": "Mortgage", "sub_product": "VA mortgage", "issue": "veteran", "complaint_what_happened": "mortgage veteran guarantee"}},
+	{"_source": {"product": "Credit card", "sub_product": "Store card", "issue": "card", "complaint_what_happened": "card store purchase"}},
+	{"_source": {"product": "Credit card", "sub_product": "Store card", "issue": "card", "complaint_what_happened": "card store rewards"}}
+]`
+
+func trainTestHierarchy(t *testing.T) *HierarchicalModel {
+	err := ioutil.WriteFile("test_data.json", []byte(hierarchicalTestData), 0666)
+	if err != nil {
+		t.Errorf("Error creating test data file: %v", err)
+	}
+	defer os.Remove("test_data.json")
+
+	dataset := NewDataset("test_data.json")
+	dataset.Mapping.ChildLabel = "sub_product"
+	model, err := TrainHierarchicalModel(dataset, NewStopWordLists(map[string]struct{}{}, nil), newTestTokenizer(t, TokenizerConfig{}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	return model
+}
+
+func TestTrainHierarchicalModel(t *testing.T) {
+	model := trainTestHierarchy(t)
+
+	if len(model.Root.Classes) != 2 {
+		t.Errorf("Expected 2 root classes, got %v", model.Root.Classes)
+	}
+	if len(model.Children) != 1 || model.Children["Mortgage"] == nil {
+		t.Errorf("Expected a child classifier for Mortgage only, got %v", model.Children)
+	}
+	if model.SingleChildren["Credit card"] != "Store card" {
+		t.Errorf("Expected Store card to be the only child of Credit card, got %v", model.SingleChildren)
+	}
+}
+
+func TestTrainHierarchicalModelWithoutChildLabel(t *testing.T) {
+	_, err := TrainHierarchicalModel(NewDataset("test_data.json"), NewStopWordLists(map[string]struct{}{}, nil), newTestTokenizer(t, TokenizerConfig{}))
+	if err == nil {
+		t.Errorf("Expected error training hierarchical model without child label")
+	}
+}
+
+func TestHierarchicalPredictor(t *testing.T) {
+	predictor, err := NewHierarchicalPredictor(trainTestHierarchy(t), map[string]struct{}{})
+	if err != nil {
+		t.Fatalf("Error creating predictor: %v", err)
+	}
+
+	result := predictor.Predict("my veteran mortgage")
+	if !reflect.DeepEqual(result.Path, []string{"Mortgage", "VA mortgage"}) {
+		t.Errorf("Test case failed: got %v, want %v", result.Path, []string{"Mortgage", "VA mortgage"})
+	}
+	expectedConfidence := result.Levels[0].Probability * result.Levels[1].Probability
+	if math.Abs(result.Confidence-expectedConfidence) > 1e-9 {
+		t.Errorf("Expected confidence %f to be a product of level probabilities, got %f", expectedConfidence, result.Confidence)
+	}
+
+	result = predictor.Predict("store card rewards")
+	if !reflect.DeepEqual(result.Path, []string{"Credit card", "Store card"}) {
+		t.Errorf("Test case failed: got %v, want %v", result.Path, []string{"Credit card", "Store card"})
+	}
+}
+
+func TestHierarchicalPredictorRankLeaves(t *testing.T) {
+	predictor, err := NewHierarchicalPredictor(trainTestHierarchy(t), map[string]struct{}{})
+	if err != nil {
+		t.Fatalf("Error creating predictor: %v", err)
+	}
+
+	expected := []string{"Credit card > Store card", "Mortgage > FHA mortgage", "Mortgage > VA mortgage"}
+	if result := predictor.LeafClasses(); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+
+	ranking := predictor.RankLeaves("my veteran mortgage")
+	if len(ranking) != 2 || ranking[0].Class != "Mortgage > VA mortgage" || ranking[1].Class != "Credit card > Store card" {
+		t.Errorf("Expected the most likely path of every root class, got %v", ranking)
+	}
+	if ranking[0].Probability != predictor.Predict("my veteran mortgage").Confidence {
+		t.Errorf("Expected leaf probability to be the path confidence, got %v", ranking)
+	}
+}
+
+func TestHierarchicalHoldoutEvaluate(t *testing.T) {
+	records := strings.TrimSuffix(strings.TrimPrefix(hierarchicalTestData, "["), "]")
+	err := ioutil.WriteFile("test_data.json", []byte("["+strings.Repeat(records+",", 9)+records+"]"), 0666)
+	if err != nil {
+		t.Errorf("Error creating test data file: %v", err)
+	}
+	defer os.Remove("test_data.json")
+
+	dataset := NewDataset("test_data.json")
+	dataset.Mapping.ChildLabel = "sub_product"
+	report, err := HierarchicalHoldoutEvaluate(dataset, NewStopWordLists(map[string]struct{}{}, nil), newTestTokenizer(t, TokenizerConfig{}), 0.25, 42)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	expected := []string{"Credit card > Store card", "Mortgage > FHA mortgage", "Mortgage > VA mortgage"}
+	if !reflect.DeepEqual(report.Classes, expected) {
+		t.Errorf("Expected report on leaf classes: got %v, want %v", report.Classes, expected)
+	}
+	if report.Samples == 0 || report.Accuracy != 1 {
+		t.Errorf("Expected held-out tickets to be classified correctly, got %d samples with accuracy %f", report.Samples, report.Accuracy)
+	}
+
+	if _, err := HierarchicalHoldoutEvaluate(NewDataset("test_data.json"), NewStopWordLists(map[string]struct{}{}, nil), newTestTokenizer(t, TokenizerConfig{}), 0.25, 42); err == nil {
+		t.Errorf("Expected error evaluating hierarchical model without child label")
+	}
+}
@@ -1,22 +1,45 @@
 package util
 
 import (
-	"log"
+	"fmt"
+	"sync"
 
 	"github.com/aaaton/golem/v4"
 	"github.com/aaaton/golem/v4/dicts/en"
 )
 
-var lem *golem.Lemmatizer
+// Reduces words to their dictionary form with the golem English dictionary.
+// The dictionary is read only after construction, so a Lemmatizer is safe for concurrent use.
+type Lemmatizer struct {
+	lemmatizer *golem.Lemmatizer
+}
 
-func Lemmatize(word string) string {
-	if lem == nil {
-		lemmatizer, err := golem.New(en.New())
-		if err != nil {
-			log.Print("Lemmatizer not working: ", err)
-			return word
-		}
-		lem = lemmatizer
+// Loads the English dictionary, which takes a while, so lemmatizers should be created once and shared.
+func NewLemmatizer() (*Lemmatizer, error) {
+	lemmatizer, err := golem.New(en.New())
+	if err != nil {
+		return nil, fmt.Errorf("can not load lemmatizer dictionary: %w", err)
 	}
-	return lem.Lemma(word)
+	return &Lemmatizer{lemmatizer: lemmatizer}, nil
+}
+
+// Returns the lower cased dictionary form of the word, or the word itself when it is not in the dictionary.
+func (l *Lemmatizer) Lemma(word string) string {
+	return l.lemmatizer.Lemma(word)
+}
+
+var (
+	sharedLemmatizer     *Lemmatizer
+	sharedLemmatizerErr  error
+	sharedLemmatizerOnce sync.Once
+)
+
+// Returns the lemmatizer shared by pipelines, loaded on the first call. Pipelines with a lemmatize
+// stage call it when they are created, so only programs that lemmatize pay for the dictionary
+// and it is loaded before any text is processed.
+func SharedLemmatizer() (*Lemmatizer, error) {
+	sharedLemmatizerOnce.Do(func() {
+		sharedLemmatizer, sharedLemmatizerErr = NewLemmatizer()
+	})
+	return sharedLemmatizer, sharedLemmatizerErr
 }
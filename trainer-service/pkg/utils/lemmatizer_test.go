@@ -1,9 +1,20 @@
 package util
 
-import "testing"
+import (
+	"sync"
+	"testing"
+)
+
+func newTestLemmatizer(t *testing.T) *Lemmatizer {
+	lemmatizer, err := SharedLemmatizer()
+	if err != nil {
+		t.Fatalf("Error loading lemmatizer: %v", err)
+	}
+	return lemmatizer
+}
 
 func TestLemmatize(t *testing.T) {
-	result := Lemmatize("jumps")
+	result := newTestLemmatizer(t).Lemma("jumps")
 	expected := "jump"
 	if result != expected {
 		t.Errorf("Test case failed: got %s, want %s", result, expected)
@@ -11,7 +22,7 @@ func TestLemmatize(t *testing.T) {
 }
 
 func TestLemmatizeAlreadyBaseForm(t *testing.T) {
-	result := Lemmatize("jump")
+	result := newTestLemmatizer(t).Lemma("jump")
 	expected := "jump"
 	if result != expected {
 		t.Errorf("Test case failed: got %s, want %s", result, expected)
@@ -19,7 +30,7 @@ func TestLemmatizeAlreadyBaseForm(t *testing.T) {
 }
 
 func TestLemmatizeUnknownWord(t *testing.T) {
-	result := Lemmatize("foobar")
+	result := newTestLemmatizer(t).Lemma("foobar")
 	expected := "foobar"
 	if result != expected {
 		t.Errorf("Test case failed: got %s, want %s", result, expected)
@@ -27,7 +38,7 @@ func TestLemmatizeUnknownWord(t *testing.T) {
 }
 
 func TestLemmatizeUppercase(t *testing.T) {
-	result := Lemmatize("JUMPS")
+	result := newTestLemmatizer(t).Lemma("JUMPS")
 	expected := "jump"
 	if result != expected {
 		t.Errorf("Test case failed: got %s, want %s", result, expected)
@@ -35,7 +46,7 @@ func TestLemmatizeUppercase(t *testing.T) {
 }
 
 func TestLemmatizeMultiplePossibleLemmas(t *testing.T) {
-	result := Lemmatize("swim")
+	result := newTestLemmatizer(t).Lemma("swim")
 	expected := "swim"
 	if result != expected {
 		t.Errorf("Test case failed: got %s, want %s", result, expected)
@@ -43,9 +54,40 @@ func TestLemmatizeMultiplePossibleLemmas(t *testing.T) {
 }
 
 func TestLemmatizeEmptyString(t *testing.T) {
-	result := Lemmatize("")
+	result := newTestLemmatizer(t).Lemma("")
 	expected := ""
 	if result != expected {
 		t.Errorf("Test case failed: got %s, want %s", result, expected)
 	}
 }
+
+func TestLemmatizerConcurrentUse(t *testing.T) {
+	words := map[string]string{"jumps": "jump", "fees": "fee", "charged": "charge", "accounts": "account"}
+
+	var wg sync.WaitGroup
+	lemmatizers := make([]*Lemmatizer, 8)
+	for i := range lemmatizers {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			lemmatizer, err := SharedLemmatizer()
+			if err != nil {
+				t.Errorf("Error getting shared lemmatizer: %v", err)
+				return
+			}
+			lemmatizers[i] = lemmatizer
+			for word, expected := range words {
+				if result := lemmatizer.Lemma(word); result != expected {
+					t.Errorf("Test case %s failed: got %s, want %s", word, result, expected)
+				}
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, lemmatizer := range lemmatizers {
+		if lemmatizer != lemmatizers[0] {
+			t.Errorf("Expected the same shared lemmatizer to be returned")
+		}
+	}
+}
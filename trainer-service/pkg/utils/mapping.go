@@ -0,0 +1,130 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+)
+
+// Returns the mapping of the Kaggle complaints data: product is the label,
+// issue and complaint_what_happened are the text.
+func DefaultFieldMapping() models.FieldMapping {
+	return models.FieldMapping{
+		Label: "product",
+		Text:  []models.TextField{{Field: "issue"}, {Field: "complaint_what_happened"}},
+	}
+}
+
+// Reads field mapping from a JSON file.
+func ReadFieldMapping(mappingFileDir string) (models.FieldMapping, error) {
+	var mapping models.FieldMapping
+
+	bytes, err := ioutil.ReadFile(mappingFileDir)
+	if err != nil {
+		return mapping, err
+	}
+
+	if err := json.Unmarshal(bytes, &mapping); err != nil {
+		return mapping, fmt.Errorf("not a valid field mapping: %w", err)
+	}
+
+	return mapping, ValidateFieldMapping(mapping)
+}
+
+func ValidateFieldMapping(mapping models.FieldMapping) error {
+	if mapping.Label == "" {
+		return errors.New("field mapping: label field is required")
+	}
+	if len(mapping.Text) == 0 {
+		return errors.New("field mapping: at least one text field is required")
+	}
+	for _, t := range mapping.Text {
+		if t.Field == "" {
+			return errors.New("field mapping: text field name is required")
+		}
+		if t.Weight < 0 {
+			return fmt.Errorf("field mapping: weight of %s can not be negative", t.Field)
+		}
+	}
+	for _, f := range mapping.Filters {
+		if f.Field == "" {
+			return errors.New("field mapping: filter field name is required")
+		}
+	}
+	return nil
+}
+
+// Turns a dataset record into a support case. Returns false when the record is
+// filtered out, has no label or has no text.
+// Weighted fields are repeated, which only changes the model when the tokenizer
+// keeps repeated tokens.
+func applyFieldMapping(mapping models.FieldMapping, record models.Record) (models.TrainingCase, bool) {
+	for _, f := range mapping.Filters {
+		if matchesFilter(f, record[f.Field]) == f.Exclude {
+			return models.TrainingCase{}, false
+		}
+	}
+
+	class := record[mapping.Label]
+	if len(class) == 0 {
+		return models.TrainingCase{}, false
+	}
+
+	text := MappedText(mapping, record)
+	if text == "" {
+		return models.TrainingCase{}, false
+	}
+	c := models.TrainingCase{Class: class, Text: text}
+	if mapping.ChildLabel != "" {
+		c.ChildClass = record[mapping.ChildLabel]
+	}
+	return c, true
+}
+
+// Joins text fields of the record, repeating weighted fields. Returns an empty
+// string when the record has none of the text fields.
+func MappedText(mapping models.FieldMapping, record models.Record) string {
+	texts := make([]string, 0, len(mapping.Text))
+	hasText := false
+	for _, t := range mapping.Text {
+		text := record[t.Field]
+		if len(text) > 0 {
+			hasText = true
+		}
+
+		weight := t.Weight
+		if weight == 0 {
+			weight = 1
+		}
+		for i := 0; i < weight; i++ {
+			texts = append(texts, text)
+		}
+	}
+
+	if !hasText {
+		return ""
+	}
+	return strings.Join(texts, " ")
+}
+
+// Returns names of the text fields of the mapping.
+func TextFields(mapping models.FieldMapping) []string {
+	fields := make([]string, len(mapping.Text))
+	for i, t := range mapping.Text {
+		fields[i] = t.Field
+	}
+	return fields
+}
+
+func matchesFilter(filter models.FieldFilter, value string) bool {
+	for _, v := range filter.Values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,115 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+)
+
+func TestReadFieldMapping(t *testing.T) {
+	err := ioutil.WriteFile("test.json", []byte(`{"label": "sub_product", "text": [{"field": "issue", "weight": 2}, {"field": "company"}], "filters": [{"field": "product", "values": ["Mortgage"]}]}`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test file: %v", err)
+	}
+	defer os.Remove("test.json")
+
+	result, err := ReadFieldMapping("test.json")
+	if err != nil {
+		t.Fatalf("Error reading field mapping: %v", err)
+	}
+	expected := models.FieldMapping{
+		Label:   "sub_product",
+		Text:    []models.TextField{{Field: "issue", Weight: 2}, {Field: "company"}},
+		Filters: []models.FieldFilter{{Field: "product", Values: []string{"Mortgage"}}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+}
+
+func TestReadFieldMappingInvalid(t *testing.T) {
+	mappings := []string{
+		`{"label": "product"`,
+		`{"text": [{"field": "issue"}]}`,
+		`{"label": "product", "text": []}`,
+		`{"label": "product", "text": [{"field": "issue", "weight": -1}]}`,
+		`{"label": "product", "text": [{"field": "issue"}], "filters": [{"values": ["a"]}]}`,
+	}
+	defer os.Remove("test.json")
+
+	for _, mapping := range mappings {
+		err := ioutil.WriteFile("test.json", []byte(mapping), 0666)
+		if err != nil {
+			t.Errorf("Error creating test file: %v", err)
+		}
+		if _, err := ReadFieldMapping("test.json"); err == nil {
+			t.Errorf("Expected error reading field mapping %s", mapping)
+		}
+	}
+}
+
+func TestReadDefaultFieldMappingFile(t *testing.T) {
+	result, err := ReadFieldMapping("../../data/field_mapping.json")
+	if err != nil {
+		t.Fatalf("Error reading field mapping: %v", err)
+	}
+	record := models.Record{"product": "Mortgage", "issue": "title", "complaint_what_happened": "description"}
+	fromFile, _ := applyFieldMapping(result, record)
+	fromDefault, _ := applyFieldMapping(DefaultFieldMapping(), record)
+	if fromFile != fromDefault {
+		t.Errorf("Expected field mapping file to match the default mapping: got %v, want %v", fromFile, fromDefault)
+	}
+}
+
+func TestApplyFieldMapping(t *testing.T) {
+	mapping := models.FieldMapping{
+		Label: "sub_product",
+		Text:  []models.TextField{{Field: "issue", Weight: 2}, {Field: "company"}},
+	}
+	record := models.Record{"product": "Mortgage", "sub_product": "FHA mortgage", "issue": "escrow", "company": "bank"}
+
+	result, ok := applyFieldMapping(mapping, record)
+	expected := models.TrainingCase{Class: "FHA mortgage", Text: "escrow escrow bank"}
+	if !ok || result != expected {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+}
+
+func TestApplyFieldMappingSkipsIncompleteRecords(t *testing.T) {
+	mapping := DefaultFieldMapping()
+
+	if _, ok := applyFieldMapping(mapping, models.Record{"issue": "title"}); ok {
+		t.Errorf("Expected record without label to be skipped")
+	}
+	if _, ok := applyFieldMapping(mapping, models.Record{"product": "class1", "issue": ""}); ok {
+		t.Errorf("Expected record without text to be skipped")
+	}
+}
+
+func TestApplyFieldMappingFilters(t *testing.T) {
+	mapping := DefaultFieldMapping()
+	mapping.Filters = []models.FieldFilter{
+		{Field: "product", Values: []string{"Mortgage", "Credit card"}},
+		{Field: "company", Values: []string{"Acme"}, Exclude: true},
+	}
+
+	records := []struct {
+		product  string
+		company  string
+		expected bool
+	}{
+		{"Mortgage", "Bank", true},
+		{"Credit card", "Lender", true},
+		{"Mortgage", "Acme", false},
+		{"Student loan", "Bank", false},
+	}
+	for _, r := range records {
+		record := models.Record{"product": r.product, "company": r.company, "issue": "title"}
+		if _, ok := applyFieldMapping(mapping, record); ok != r.expected {
+			t.Errorf("Test case failed for %s from %s: got %v, want %v", r.product, r.company, ok, r.expected)
+		}
+	}
+}
@@ -1,104 +1,195 @@
 package util
 
 import (
+	"fmt"
 	"log"
+	"sort"
 	"sync"
 
 	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
 	"github.com/navossoc/bayesian"
 )
 
-var wg sync.WaitGroup
-
 const (
 	MAX_GO_ROUTINES = 10
 )
 
-//Reads support cases data, reads the model from the file, and generates a new model if necessary.
-//It then waits for all the support cases to be trained before exiting.
-func GetBaseModel(modelFileDir string, trainDataDir string, stopWordsDir string) (*bayesian.Classifier, error) {
-	classifier, errorReadingModel := ReadModelFromFile(modelFileDir)
+// Trains a new flat model from support cases, bundled with the stop words, tokenizer config,
+// field mapping and fingerprint it was trained with.
+func TrainModelBundle(dataset Dataset, stopWordsDir string, tokenizer *Tokenizer) (*ModelBundle, error) {
+	stopWordsFile, errorReadStopWords := ReadStopWordsFile(stopWordsDir)
 
-	if errorReadingModel != nil {
-		log.Print("Can not read model from file: ", modelFileDir)
+	if errorReadStopWords != nil {
+		return nil, errorReadStopWords
 	}
 
-	if classifier == nil {
-		if classifier != nil {
-			log.Print("There are more new classes in test data")
-		}
-
-		cases, stopWords, errorReadData := ReadTrainingData(trainDataDir, stopWordsDir)
-
-		if errorReadData != nil {
-			log.Panic(errorReadData)
-			return nil, errorReadData
-		}
-
-		var classes []bayesian.Class
-
-		for k := range cases {
-			class := bayesian.Class(k)
-			classes = append(classes, class)
-		}
-
-		log.Print("Generating new model")
-		classifier = CreateClassifierFromTestData(classes, cases, stopWords)
-		modelWriteErr := WriteModelToFile(modelFileDir, classifier)
-		if modelWriteErr != nil {
-			log.Panic(modelWriteErr)
-			return nil, modelWriteErr
-		}
-	} else {
-		log.Printf("Found existing model with [%d classes] learned and [%d words] learned for every class", classifier.Learned(), classifier.WordCount())
+	fingerprint, errorFingerprint := NewModelFingerprint(dataset, stopWordsDir, tokenizer.Config(), MODEL_KIND_FLAT)
+	if errorFingerprint != nil {
+		return nil, errorFingerprint
 	}
-	wg.Wait()
 
-	return classifier, nil
+	log.Print("Generating new model")
+	vocabularies, _, errorTraining := streamVocabularies(dataset.Stream, NewStopWordListsFromFile(stopWordsFile), tokenizer)
+	if errorTraining != nil {
+		return nil, errorTraining
+	}
+	classifier, errorTraining := classifierFromVocabularies(vocabularies, tokenizer)
+	if errorTraining != nil {
+		return nil, errorTraining
+	}
+	log.Printf("Model fingerprint %s", fingerprint.Fingerprint)
+
+	return &ModelBundle{
+		Manifest:   ModelManifest{Mapping: dataset.Mapping, Fingerprint: fingerprint, Tickets: ticketCounts(vocabularies)},
+		Classifier: classifier,
+		StopWords:  stopWordsFile,
+		Tokenizer:  tokenizer.Config(),
+	}, nil
 }
 
-// Creates a classifier from support cases, given the classes and stop words.
-func CreateClassifierFromTestData(classes []bayesian.Class, cases map[string][]string, stopWords map[string]struct{}) *bayesian.Classifier {
-	classifier := ParallelClassifierTraining(cases, classes, stopWords)
-	classifier.ConvertTermsFreqToTfIdf()
-	return classifier
+// Counts of tokens of all tickets of a class and class stop words removed from them.
+type classVocabulary struct {
+	tokens  map[string]int
+	tickets int
+	removed map[string]int
 }
 
-// Trains a classifier in parallel using multiple goroutines.
+func (v *classVocabulary) add(tokens []string, removed map[string]int) {
+	for _, token := range tokens {
+		v.tokens[token]++
+	}
+	for word, count := range removed {
+		v.removed[word] += count
+	}
+	v.tickets++
+}
 
-func ParallelClassifierTraining(cases map[string][]string, classes []bayesian.Class, stopWords map[string]struct{}) *bayesian.Classifier {
-	classifier := bayesian.NewClassifier(classes...)
-	log.Printf("Found %d classes", len(classes))
+// Tokens of a single ticket and class stop words removed from it.
+type tokenizedCase struct {
+	trainingCase models.TrainingCase
+	tokens       []string
+	removed      map[string]int
+}
 
-	tasksChannel := make(chan models.Pair[string, []string])
+// Streams cases collecting vocabularies of classes, and of child classes
+// within every class when cases have them. stream is Dataset.Stream or a filter of it.
+func streamVocabularies(stream func(fn func(models.TrainingCase) error) error, stopWords *StopWordLists, tokenizer *Tokenizer) (map[string]*classVocabulary, map[string]map[string]*classVocabulary, error) {
+	cases := make(chan models.TrainingCase, MAX_GO_ROUTINES)
+	tokenized := make(chan tokenizedCase, MAX_GO_ROUTINES)
 
+	var workers sync.WaitGroup
 	for i := 0; i < MAX_GO_ROUTINES; i++ {
-		wg.Add(1)
+		workers.Add(1)
 		go func() {
-			for sc := range tasksChannel {
-				tokens := Tokenize(sc.Second, stopWords)
-				classifier.Learn(tokens, bayesian.Class(sc.First))
-				log.Printf("Trained '%s' class with %d tickets", sc.First, len(sc.Second))
+			defer workers.Done()
+			for c := range cases {
+				tokenized <- tokenizeCase(c, stopWords, tokenizer)
 			}
-			wg.Done()
 		}()
 	}
 
-	for k, v := range cases {
-		tasksChannel <- models.Pair[string, []string]{First: k, Second: v}
+	go func() {
+		workers.Wait()
+		close(tokenized)
+	}()
+
+	// Tickets arrive in any order, but merging only sums counts, so vocabularies are the same on every run.
+	vocabularies := make(map[string]*classVocabulary)
+	children := make(map[string]map[string]*classVocabulary)
+	merged := make(chan struct{})
+
+	go func() {
+		for t := range tokenized {
+			class, child := t.trainingCase.Class, t.trainingCase.ChildClass
+			vocabularyOf(vocabularies, class).add(t.tokens, t.removed)
+
+			if child != "" {
+				if children[class] == nil {
+					children[class] = make(map[string]*classVocabulary)
+				}
+				vocabularyOf(children[class], child).add(t.tokens, nil)
+			}
+		}
+		close(merged)
+	}()
+
+	errReading := stream(func(c models.TrainingCase) error {
+		cases <- c
+		return nil
+	})
+	close(cases)
+	<-merged
+
+	if errReading != nil {
+		return nil, nil, errReading
+	}
+	return vocabularies, children, nil
+}
+
+func tokenizeCase(c models.TrainingCase, stopWords *StopWordLists, tokenizer *Tokenizer) tokenizedCase {
+	texts := []string{c.Text}
+	return tokenizedCase{
+		trainingCase: c,
+		tokens:       tokenizer.Tokenize(texts, stopWords.ForClass(c.Class)),
+		removed:      stopWords.countClassStopWords(c.Class, texts),
 	}
+}
 
-	close(tasksChannel)
+// Returns the number of tickets of every class of the vocabularies.
+func ticketCounts(vocabularies map[string]*classVocabulary) map[string]int {
+	counts := make(map[string]int, len(vocabularies))
+	for class, vocabulary := range vocabularies {
+		counts[class] = vocabulary.tickets
+	}
+	return counts
+}
 
-	wg.Wait()
-	return classifier
+func vocabularyOf(vocabularies map[string]*classVocabulary, class string) *classVocabulary {
+	if vocabularies[class] == nil {
+		vocabularies[class] = &classVocabulary{tokens: make(map[string]int), removed: make(map[string]int)}
+	}
+	return vocabularies[class]
+}
+
+// Creates a classifier learning every class found in the vocabularies in sorted order.
+func classifierFromVocabularies(vocabularies map[string]*classVocabulary, tokenizer *Tokenizer) (*bayesian.Classifier, error) {
+	if len(vocabularies) < 2 {
+		return nil, fmt.Errorf("at least 2 classes are required to train a model, found %d", len(vocabularies))
+	}
+
+	var classes []bayesian.Class
+	for class := range vocabularies {
+		classes = append(classes, bayesian.Class(class))
+	}
+	sort.Slice(classes, func(i, j int) bool { return classes[i] < classes[j] })
+	log.Printf("Found %d classes", len(classes))
+
+	classifier := learnVocabularies(classes, vocabularies, tokenizer)
+	classifier.ConvertTermsFreqToTfIdf()
+	return classifier, nil
 }
 
-func classify(text string, classes []models.FileTestData, stopWords map[string]struct{}, classifier *bayesian.Classifier) (string, float64) {
-	testTexts := make([]string, 0)
-	testTexts = append(testTexts, text)
-	tokenized := Tokenize(testTexts, stopWords)
-	probs, likely, _ := classifier.ProbScores(tokenized)
-	likely_class := classes[likely].Class
-	return likely_class, probs[likely] * 100
+// Learns every class from its vocabulary in the order of classes. With binary term
+// frequency every token is learned once, otherwise as many times as it was counted.
+func learnVocabularies(classes []bayesian.Class, vocabularies map[string]*classVocabulary, tokenizer *Tokenizer) *bayesian.Classifier {
+	classifier := bayesian.NewClassifier(classes...)
+
+	for _, class := range classes {
+		vocabulary := vocabularies[string(class)]
+		tokens := make([]string, 0, len(vocabulary.tokens))
+		for token := range vocabulary.tokens {
+			tokens = append(tokens, token)
+		}
+		sort.Strings(tokens)
+
+		classifier.Learn(tokens, class)
+		if !tokenizer.Config().binary() {
+			for _, token := range tokens {
+				classifier.Observe(token, vocabulary.tokens[token]-1, class)
+			}
+		}
+		log.Printf("Trained '%s' class with %d tickets", class, vocabulary.tickets)
+		logRemovedStopWords(string(class), vocabulary.removed)
+	}
+	return classifier
 }
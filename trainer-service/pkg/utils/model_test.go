@@ -1,37 +1,103 @@
 package util
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
+	"reflect"
+	"strings"
 	"testing"
 
 	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
 	"github.com/navossoc/bayesian"
 )
 
-func TestGetExistingBaseModel(t *testing.T) {
-	cases := map[string][]string{
-		"class1": {"This is a test sentence for class 1", "This is another test sentence for class 1"},
-		"class2": {"This is a test sentence for class 2", "This is another test sentence for class 2"},
+// Trains a classifier on the cases through the streaming training path.
+func trainTestClassifier(t *testing.T, cases map[string][]string, stopWords *StopWordLists, tokenizer *Tokenizer) *bayesian.Classifier {
+	stream := func(fn func(models.TrainingCase) error) error {
+		for class, texts := range cases {
+			for _, text := range texts {
+				if err := fn(models.TrainingCase{Class: class, Text: text}); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
 	}
-	stopWords := map[string]struct{}{"is": {}}
-	classifier := CreateClassifierFromTestData([]bayesian.Class{"class1", "class2"}, cases, stopWords)
-	err := WriteModelToFile("test_dir/test_model.gob", classifier)
+	classifier, err := trainStreamedClassifier(stream, stopWords, tokenizer)
 	if err != nil {
-		t.Errorf("Error writing model to file: %v", err)
+		t.Fatalf("Error training classifier: %v", err)
 	}
-	defer os.RemoveAll("test_dir")
-	baseModel, err := GetBaseModel("test_dir/test_model.gob", "", "")
+	return classifier
+}
+
+func trainStreamedClassifier(stream func(fn func(models.TrainingCase) error) error, stopWords *StopWordLists, tokenizer *Tokenizer) (*bayesian.Classifier, error) {
+	vocabularies, _, err := streamVocabularies(stream, stopWords, tokenizer)
 	if err != nil {
-		t.Errorf("Unexpected error: %v", err)
+		return nil, err
+	}
+	return classifierFromVocabularies(vocabularies, tokenizer)
+}
+
+func TestClassifierFromVocabularies(t *testing.T) {
+	cases := map[string][]string{
+		"class1": {"This is a test case for class 1", "This is another test case for class 1"},
+		"class2": {"This is a test case for class 2", "This is another test case for class 2"},
+	}
+	stopWords := NewStopWordLists(map[string]struct{}{"for": {}}, nil)
+
+	classifier := trainTestClassifier(t, cases, stopWords, newTestTokenizer(t, TokenizerConfig{}))
+
+	expected := []bayesian.Class{"class1", "class2"}
+	if !reflect.DeepEqual(classifier.Classes, expected) {
+		t.Errorf("Test case failed: got %v, want %v", classifier.Classes, expected)
+	}
+	if classifier.Learned() != len(expected) {
+		t.Errorf("Expected %d classes, got %d", len(expected), classifier.Learned())
+	}
+}
+
+// Run with -race to check that tickets are tokenized concurrently without data races.
+func TestStreamVocabulariesConcurrent(t *testing.T) {
+	cases := make(map[string][]string)
+	for i := 0; i < 3*MAX_GO_ROUTINES; i++ {
+		cases[fmt.Sprintf("class%d", i)] = []string{fmt.Sprintf("charged fees on accounts %s", strings.Repeat("x", i+1))}
 	}
-	if baseModel.Learned() != 2 {
-		t.Errorf("Incorrect number of classes learned")
+
+	classifier := trainTestClassifier(t, cases, NewStopWordLists(map[string]struct{}{"on": {}}, nil), newTestTokenizer(t, TokenizerConfig{}))
+	if classifier.Learned() != len(cases) {
+		t.Errorf("Expected %d classes learned, got %d", len(cases), classifier.Learned())
+	}
+	if _, ok := classifier.WordsByClass("class0")["charge"]; !ok {
+		t.Errorf("Expected lemmatized words to be learned, got %v", classifier.WordsByClass("class0"))
 	}
 }
 
-func TestTrainingNewBaseModel(t *testing.T) {
-	err := ioutil.WriteFile("test_data.json", []byte(`[{"_source": {"issue": "title1", "complaint_what_happened": "description1", "product": "class1"}}, {"_source": {"issue": "title2", "complaint_what_happened": "description2", "product": "class1"}}, {"_source": {"issue": "title3", "complaint_what_happened": "description3", "product": "class2"}}, {"_source": {"issue": "title4", "complaint_what_happened": "description4", "product": "class2"}}]`), 0666)
+func TestStreamVocabulariesIsDeterministic(t *testing.T) {
+	cases := map[string][]string{
+		"class1": {"late fee on my card", "card fee fee", "another late fee"},
+		"class2": {"mortgage escrow", "escrow payment on mortgage"},
+		"class3": {"credit report error", "wrong credit report"},
+	}
+	stopWords := NewStopWordLists(map[string]struct{}{"on": {}}, nil)
+	config := TokenizerConfig{TermFrequency: TERM_FREQUENCY_RAW, NGrams: 2}
+
+	first := trainTestClassifier(t, cases, stopWords, newTestTokenizer(t, config))
+	for i := 0; i < 5; i++ {
+		next := trainTestClassifier(t, cases, stopWords, newTestTokenizer(t, config))
+		if !reflect.DeepEqual(next.Classes, first.Classes) {
+			t.Errorf("Test case failed: got %v, want %v", next.Classes, first.Classes)
+		}
+		for _, class := range first.Classes {
+			if !reflect.DeepEqual(next.WordsByClass(class), first.WordsByClass(class)) {
+				t.Errorf("Expected the same words for %s on every run: got %v, want %v", class, next.WordsByClass(class), first.WordsByClass(class))
+			}
+		}
+	}
+}
+
+func TestTrainModelReplacesExistingModel(t *testing.T) {
+	err := ioutil.WriteFile("test_data.json", []byte(`[{"_source": {"issue": "title1", "complaint_what_happened": "description1", "product": "class1"}}, {"_source": {"issue": "title3", "complaint_what_happened": "description3", "product": "class2"}}, {"_source": {"issue": "title4", "complaint_what_happened": "description4", "product": "class3"}}]`), 0666)
 	if err != nil {
 		t.Errorf("Error creating test data file: %v", err)
 	}
@@ -43,77 +109,145 @@ func TestTrainingNewBaseModel(t *testing.T) {
 	}
 	defer os.Remove("stop_words.json")
 
-	baseModel, err := GetBaseModel("test_dir/test_model.gob", "test_data.json", "stop_words.json")
+	existing := bayesian.NewClassifier(bayesian.Class("class1"), bayesian.Class("class2"))
+	err = WriteModelBundle("test_dir/test_model.gob", &ModelBundle{Classifier: existing})
+	if err != nil {
+		t.Errorf("Error writing model bundle: %v", err)
+	}
+	defer os.RemoveAll("test_dir")
+
+	trained, err := TrainModelBundle(NewDataset("test_data.json"), "stop_words.json", newTestTokenizer(t, TokenizerConfig{}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := WriteModelBundle("test_dir/test_model.gob", trained); err != nil {
+		t.Fatalf("Error writing model bundle: %v", err)
+	}
+
+	bundle, err := ReadModelBundle("test_dir/test_model.gob")
 	if err != nil {
-		t.Errorf("Unexpected error: %v", err)
+		t.Fatalf("Error reading model from file: %v", err)
 	}
-	if baseModel.Learned() != 2 {
-		t.Errorf("Incorrect number of classes learned")
+	if len(bundle.Classifier.Classes) != 3 {
+		t.Errorf("Expected existing model to be replaced with 3 classes model, got %d classes", len(bundle.Classifier.Classes))
+	}
+	expectedTickets := map[string]int{"class1": 1, "class2": 1, "class3": 1}
+	if !reflect.DeepEqual(bundle.Manifest.Tickets, expectedTickets) {
+		t.Errorf("Test case failed: got %v, want %v", bundle.Manifest.Tickets, expectedTickets)
+	}
+}
+
+func TestTrainModelWritesTokenizerConfig(t *testing.T) {
+	err := ioutil.WriteFile("test_data.json", []byte(`[{"_source": {"issue": "late fee", "complaint_what_happened": "", "product": "class1"}}, {"_source": {"issue": "credit report", "complaint_what_happened": "", "product": "class2"}}]`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test data file: %v", err)
+	}
+	defer os.Remove("test_data.json")
+
+	err = ioutil.WriteFile("stop_words.json", []byte(`[]`), 0666)
+	if err != nil {
+		t.Errorf("Error creating stop words file: %v", err)
 	}
+	defer os.Remove("stop_words.json")
 	defer os.RemoveAll("test_dir")
+
+	trained, err := TrainModelBundle(NewDataset("test_data.json"), "stop_words.json", newTestTokenizer(t, TokenizerConfig{NGrams: 2}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, ok := trained.Classifier.WordsByClass("class1")["late fee"]; !ok {
+		t.Errorf("Expected bigram to be learned, got %v", trained.Classifier.WordsByClass("class1"))
+	}
+	if err := WriteModelBundle("test_dir/test_model.gob", trained); err != nil {
+		t.Fatalf("Error writing model bundle: %v", err)
+	}
+
+	bundle, err := ReadModelBundle("test_dir/test_model.gob")
+	if err != nil {
+		t.Fatalf("Error reading model bundle: %v", err)
+	}
+	if bundle.Tokenizer.NGrams != 2 {
+		t.Errorf("Expected tokenizer config to be stored with the model, got %+v", bundle.Tokenizer)
+	}
 }
 
-func TestCreateClassifierFromTestData(t *testing.T) {
-	// Prepare test data
-	classes := []bayesian.Class{"class1", "class2"}
+func TestTrainModelBundleMissingData(t *testing.T) {
+	_, err := TrainModelBundle(NewDataset("missing.json"), "missing.json", newTestTokenizer(t, TokenizerConfig{}))
+	if err == nil {
+		t.Errorf("Expected error training model without data")
+	}
+}
+
+func TestStreamVocabulariesFromDataset(t *testing.T) {
+	err := ioutil.WriteFile("test_data.json", []byte(`[{"_source": {"issue": "late fee", "complaint_what_happened": "card charged a late fee", "product": "class1"}}, {"_source": {"issue": "escrow", "complaint_what_happened": "mortgage escrow is wrong", "product": "class2"}}, {"_source": {"issue": "fee", "complaint_what_happened": "another card fee", "product": "class1"}}, {"_source": {"issue": "", "complaint_what_happened": "", "product": "class3"}}]`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test data file: %v", err)
+	}
+	defer os.Remove("test_data.json")
+
+	stopWords := NewStopWordLists(map[string]struct{}{"a": {}, "is": {}}, nil)
+	streamed, err := trainStreamedClassifier(NewDataset("test_data.json").Stream, stopWords, newTestTokenizer(t, TokenizerConfig{}))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
 	cases := map[string][]string{
-		"class1": {"This is a test case for class 1", "This is another test case for class 1"},
-		"class2": {"This is a test case for class 2", "This is another test case for class 2"},
+		"class1": {"late fee card charged a late fee", "fee another card fee"},
+		"class2": {"escrow mortgage escrow is wrong"},
 	}
-	stopWords := map[string]struct{}{"for": {}}
+	expected := trainTestClassifier(t, cases, stopWords, newTestTokenizer(t, TokenizerConfig{}))
 
-	// Call the function being tested
-	classifier := CreateClassifierFromTestData(classes, cases, stopWords)
+	if len(streamed.Classes) != 2 {
+		t.Errorf("Expected tickets without text to be skipped, got %v", streamed.Classes)
+	}
+	for _, class := range expected.Classes {
+		if !reflect.DeepEqual(streamed.WordsByClass(class), expected.WordsByClass(class)) {
+			t.Errorf("Expected streamed model to learn the same words for %s: got %v, want %v", class, streamed.WordsByClass(class), expected.WordsByClass(class))
+		}
+	}
+}
 
-	// Check if the returned classifier is not nil
-	if classifier == nil {
-		t.Error("Expected classifier, got nil")
+func TestStreamVocabulariesTermFrequency(t *testing.T) {
+	cases := map[string][]string{
+		"class1": {"late fee fee fee fee on card", "fee card"},
+		"class2": {"escrow escrow escrow"},
 	}
+	stopWords := NewStopWordLists(map[string]struct{}{}, nil)
 
-	// Check if the number of classes learned is equal to the expected number of classes
-	if classifier.Learned() != len(classes) {
-		t.Errorf("Expected %d classes, got %d", len(classes), classifier.Learned())
+	raw := trainTestClassifier(t, cases, stopWords, newTestTokenizer(t, TokenizerConfig{TermFrequency: TERM_FREQUENCY_RAW}))
+	binary := trainTestClassifier(t, cases, stopWords, newTestTokenizer(t, TokenizerConfig{}))
+	if raw.WordsByClass("class1")["fee"] <= binary.WordsByClass("class1")["fee"] {
+		t.Errorf("Expected repeated word to weigh more with raw term frequency: got %v, binary %v", raw.WordsByClass("class1"), binary.WordsByClass("class1"))
 	}
 
+	capped := trainTestClassifier(t, cases, stopWords, newTestTokenizer(t, TokenizerConfig{TermFrequency: TERM_FREQUENCY_CAP, MaxTermFrequency: 2}))
+	if capped.WordsByClass("class1")["fee"] >= raw.WordsByClass("class1")["fee"] {
+		t.Errorf("Expected capped term frequency to weigh repeated word less than raw: got %v, raw %v", capped.WordsByClass("class1"), raw.WordsByClass("class1"))
+	}
 }
 
-func TestClassify(t *testing.T) {
-	classes := []models.FileTestData{
-		{Class: "class1"},
-		{Class: "class2"},
-		{Class: "class3"},
-	}
-	stopWords := map[string]struct{}{
-		"the": {},
-		"is":  {},
-	}
-	classifier := bayesian.NewClassifier(bayesian.Class("class1"), bayesian.Class("class2"), bayesian.Class("class3"))
-	classifier.Learn([]string{"this", "is", "a", "text"}, bayesian.Class("class1"))
-	classifier.Learn([]string{"this", "is", "another", "text"}, bayesian.Class("class2"))
-	classifier.Learn([]string{"yet", "another", "text"}, bayesian.Class("class3"))
-
-	t.Run("Correct classification for class1", func(t *testing.T) {
-		text := "this is a text"
-		result, score := classify(text, classes, stopWords, classifier)
-		expected := "class1"
-		if result != expected {
-			t.Errorf("Expected %s, got %s", expected, result)
-		}
+func TestStreamVocabulariesSingleClass(t *testing.T) {
+	err := ioutil.WriteFile("test_data.json", []byte(`[{"_source": {"issue": "title1", "complaint_what_happened": "description1", "product": "class1"}}]`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test data file: %v", err)
+	}
+	defer os.Remove("test_data.json")
 
-		if score < 90 {
-			t.Errorf("Expected %f score, to be more than 90", score)
-		}
-	})
-
-	t.Run("Correct classification for class2", func(t *testing.T) {
-		text := "this is another text"
-		result, score := classify(text, classes, stopWords, classifier)
-		expected := "class2"
-		if result != expected {
-			t.Errorf("Expected %s, got %s", expected, result)
-		}
-		if score < 90 {
-			t.Errorf("Expected %f score, to be more than 90", score)
-		}
-	})
+	_, err = trainStreamedClassifier(NewDataset("test_data.json").Stream, NewStopWordLists(map[string]struct{}{}, nil), newTestTokenizer(t, TokenizerConfig{}))
+	if err == nil {
+		t.Errorf("Expected error training a model with a single class")
+	}
+}
+
+func TestStreamVocabulariesInvalidJSON(t *testing.T) {
+	err := ioutil.WriteFile("test_data.json", []byte(`[{"_source": {"issue": "title1", "complaint_what_happened": "description1", "product": "class1"}}, {"_sour`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test data file: %v", err)
+	}
+	defer os.Remove("test_data.json")
+
+	_, err = trainStreamedClassifier(NewDataset("test_data.json").Stream, NewStopWordLists(map[string]struct{}{}, nil), newTestTokenizer(t, TokenizerConfig{}))
+	if err == nil {
+		t.Errorf("Expected error reading invalid JSON data")
+	}
 }
@@ -0,0 +1,47 @@
+package util
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Placeholders replacing values that identify people, accounts or amounts.
+const (
+	PLACEHOLDER_EMAIL   = "__EMAIL__"
+	PLACEHOLDER_DATE    = "__DATE__"
+	PLACEHOLDER_AMOUNT  = "__AMOUNT__"
+	PLACEHOLDER_PHONE   = "__PHONE__"
+	PLACEHOLDER_ACCOUNT = "__ACCOUNT__"
+	PLACEHOLDER_MASKED  = "__MASKED__"
+)
+
+type normalizationRule struct {
+	pattern     *regexp.Regexp
+	placeholder string
+}
+
+// Rules are applied in order, so that e.g. masked dates are not taken for masked words.
+var normalizationRules = []normalizationRule{
+	{regexp.MustCompile(`(?i)[a-z0-9._%+-]+@[a-z0-9.-]+\.[a-z]{2,}`), PLACEHOLDER_EMAIL},
+	{regexp.MustCompile(`(?i)\b(?:(?:\d{1,2}|x{2})[/.-](?:\d{1,2}|x{2})[/.-](?:\d{4}|\d{2}|x{2,4})|\d{4}-\d{1,2}-\d{1,2})\b`), PLACEHOLDER_DATE},
+	{regexp.MustCompile(`(?i)\$\s?\{?\$?\s?\d[\d,]*(?:\.\d+)?\}?|\b\d[\d,]*(?:\.\d+)?\s?(?:dollars|usd)\b`), PLACEHOLDER_AMOUNT},
+	{regexp.MustCompile(`(?:\(\d{3}\)\s?|\b\d{3}[.-])\d{3}[.-]\d{4}\b`), PLACEHOLDER_PHONE},
+	{regexp.MustCompile(`(?i)\b(?:x{4}[\s-]?){1,3}(?:\d{3,4}|x{4})\b|\b\d(?:[\s-]?\d){7,18}\b`), PLACEHOLDER_ACCOUNT},
+	{regexp.MustCompile(`(?i)\bx{2,}\b`), PLACEHOLDER_MASKED},
+}
+
+// Replaces emails, dates, amounts, phone, card and account numbers, and masked
+// sequences like XXXX with placeholders, so that models learn from their presence
+// but not from their values.
+func Normalize(text string) string {
+	// Underscores are kept in words of normalized texts, as they are part of placeholders.
+	text = strings.ReplaceAll(text, "_", " ")
+	for _, rule := range normalizationRules {
+		text = rule.pattern.ReplaceAllString(text, " "+rule.placeholder+" ")
+	}
+	return text
+}
+
+func isPlaceholder(token string) bool {
+	return len(token) > 4 && strings.HasPrefix(token, "__") && strings.HasSuffix(token, "__")
+}
@@ -0,0 +1,56 @@
+package util
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalizePipeline(t *testing.T) {
+	pipeline, err := NewPipeline([]StageConfig{{Type: STAGE_NORMALIZE}, {Type: STAGE_SPLIT}, {Type: STAGE_LOWERCASE}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		text     string
+		expected []string
+	}{
+		{"Write to john.doe@example.com today", []string{"write", "to", PLACEHOLDER_EMAIL, "today"}},
+		{"Paid on XX/XX/2019 and 01/15/2020", []string{"paid", "on", PLACEHOLDER_DATE, "and", PLACEHOLDER_DATE}},
+		{"Charged on 2019-03-04", []string{"charged", "on", PLACEHOLDER_DATE}},
+		{"A fee of {$500.00} and $1,250", []string{"a", "fee", "of", PLACEHOLDER_AMOUNT, "and", PLACEHOLDER_AMOUNT}},
+		{"Refund 300 dollars", []string{"refund", PLACEHOLDER_AMOUNT}},
+		{"Call (555) 123-4567 or 555.123.4567", []string{"call", PLACEHOLDER_PHONE, "or", PLACEHOLDER_PHONE}},
+		{"Card XXXX-XXXX-XXXX-1234 and account 123456789012", []string{"card", PLACEHOLDER_ACCOUNT, "and", "account", PLACEHOLDER_ACCOUNT}},
+		{"XXXX told me XX times", []string{PLACEHOLDER_MASKED, "told", "me", PLACEHOLDER_MASKED, "times"}},
+		{"snake_case __AMOUNT__ text", []string{"snake", "case", "amount", "text"}},
+	}
+	for _, test := range tests {
+		if result := pipeline.Process(test.text, nil); !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Test case %q failed: got %v, want %v", test.text, result, test.expected)
+		}
+	}
+}
+
+func TestTokenizeNormalize(t *testing.T) {
+	config := TokenizerConfig{Normalize: true}
+	result := newTestTokenizer(t, config).Tokenize([]string{"XXXX charged fees of $500 on XX/XX/2019"}, map[string]struct{}{"on": {}, "of": {}})
+	expected := []string{PLACEHOLDER_MASKED, "charge", "fee", PLACEHOLDER_AMOUNT, PLACEHOLDER_DATE}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+
+	result = newTestTokenizer(t, TokenizerConfig{}).Tokenize([]string{"XXXX charged $500"}, map[string]struct{}{})
+	expected = []string{"xxxx", "charge"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected texts not to be normalized by default: got %v, want %v", result, expected)
+	}
+}
+
+func TestLemmatizeStageKeepsPlaceholders(t *testing.T) {
+	result := LemmatizeStage(newTestLemmatizer(t)).Process([]string{PLACEHOLDER_AMOUNT, "fees"}, nil)
+	expected := []string{PLACEHOLDER_AMOUNT, "fee"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+}
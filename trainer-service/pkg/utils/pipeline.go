@@ -0,0 +1,201 @@
+package util
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Stage types of the text processing pipeline.
+const (
+	STAGE_LOWERCASE  = "lowercase"
+	STAGE_NORMALIZE  = "normalize"
+	STAGE_SPLIT      = "split"
+	STAGE_STOP_WORDS = "stop_words"
+	STAGE_LEMMATIZE  = "lemmatize"
+	STAGE_STEM       = "stem"
+	STAGE_NGRAMS     = "ngrams"
+)
+
+// Single step of text processing. Stages before STAGE_SPLIT get the whole text as
+// the only token. Stop words are the ones of the class being trained or predicted.
+type Stage interface {
+	Process(tokens []string, stopWords map[string]struct{}) []string
+}
+
+// Declares a stage of the pipeline in the tokenizer config.
+type StageConfig struct {
+	Type string `json:"type"`
+	// Longest n-gram of STAGE_NGRAMS.
+	N int `json:"n,omitempty"`
+}
+
+// Creates a stage from its config, failing when the config is not valid.
+type StageFactory func(config StageConfig) (Stage, error)
+
+// Guards stageFactories, as stages can be registered while tokenizers are created.
+var stageFactoriesMu sync.RWMutex
+
+var stageFactories = map[string]StageFactory{
+	STAGE_LOWERCASE:  simpleStage(lowercaseStage),
+	STAGE_NORMALIZE:  simpleStage(normalizeStage),
+	STAGE_SPLIT:      simpleStage(splitStage),
+	STAGE_STOP_WORDS: simpleStage(stopWordsStage),
+	STAGE_LEMMATIZE:  newLemmatizeStage,
+	STAGE_STEM:       simpleStage(stemStage),
+	STAGE_NGRAMS:     newNGramsStage,
+}
+
+// Makes a new stage type available to tokenizer configs. Stages are not stored with
+// the model, so the stage must be registered by both the trainer and the service.
+func RegisterStage(stageType string, factory StageFactory) {
+	stageFactoriesMu.Lock()
+	defer stageFactoriesMu.Unlock()
+	stageFactories[stageType] = factory
+}
+
+func stageFactory(stageType string) (StageFactory, bool) {
+	stageFactoriesMu.RLock()
+	defer stageFactoriesMu.RUnlock()
+	factory, ok := stageFactories[stageType]
+	return factory, ok
+}
+
+// Returns registered stage types, sorted.
+func StageTypes() []string {
+	stageFactoriesMu.RLock()
+	defer stageFactoriesMu.RUnlock()
+	types := make([]string, 0, len(stageFactories))
+	for t := range stageFactories {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Stages applied to every text in order.
+type Pipeline struct {
+	stages []Stage
+}
+
+func NewPipeline(configs []StageConfig) (*Pipeline, error) {
+	pipeline := &Pipeline{stages: make([]Stage, 0, len(configs))}
+	for i, config := range configs {
+		factory, ok := stageFactory(config.Type)
+		if !ok {
+			return nil, fmt.Errorf("stage %d: unknown stage type %q, expected one of %s", i, config.Type, strings.Join(StageTypes(), ", "))
+		}
+		stage, err := factory(config)
+		if err != nil {
+			return nil, fmt.Errorf("stage %d: %w", i, err)
+		}
+		pipeline.stages = append(pipeline.stages, stage)
+	}
+	return pipeline, nil
+}
+
+// Processes the text with every stage and returns its tokens.
+func (p *Pipeline) Process(text string, stopWords map[string]struct{}) []string {
+	tokens := []string{text}
+	for _, stage := range p.stages {
+		tokens = stage.Process(tokens, stopWords)
+	}
+	return tokens
+}
+
+type stageFunc func(tokens []string, stopWords map[string]struct{}) []string
+
+func (f stageFunc) Process(tokens []string, stopWords map[string]struct{}) []string {
+	return f(tokens, stopWords)
+}
+
+// Creates a factory of a stage without options.
+func simpleStage(f stageFunc) StageFactory {
+	return func(config StageConfig) (Stage, error) {
+		return f, nil
+	}
+}
+
+// Lower cases tokens, except placeholders. Placeholders are recognized only after STAGE_SPLIT.
+func lowercaseStage(tokens []string, stopWords map[string]struct{}) []string {
+	for i, token := range tokens {
+		if !isPlaceholder(token) {
+			tokens[i] = strings.ToLower(token)
+		}
+	}
+	return tokens
+}
+
+func normalizeStage(tokens []string, stopWords map[string]struct{}) []string {
+	for i, token := range tokens {
+		tokens[i] = Normalize(token)
+	}
+	return tokens
+}
+
+// Splits tokens into words of letters, keeping placeholders.
+func splitStage(tokens []string, stopWords map[string]struct{}) []string {
+	result := make([]string, 0)
+	for _, token := range tokens {
+		for _, word := range strings.FieldsFunc(token, func(r rune) bool { return !unicode.IsLetter(r) && r != '_' }) {
+			if isPlaceholder(word) {
+				result = append(result, word)
+				continue
+			}
+			result = append(result, strings.FieldsFunc(word, func(r rune) bool { return r == '_' })...)
+		}
+	}
+	return result
+}
+
+func stopWordsStage(tokens []string, stopWords map[string]struct{}) []string {
+	result := []string{}
+	for _, token := range tokens {
+		if _, ok := stopWords[strings.ToLower(token)]; !ok {
+			result = append(result, token)
+		}
+	}
+	return result
+}
+
+// Lemmatizes tokens with the shared lemmatizer, failing when its dictionary can not be loaded.
+func newLemmatizeStage(config StageConfig) (Stage, error) {
+	lemmatizer, err := SharedLemmatizer()
+	if err != nil {
+		return nil, err
+	}
+	return LemmatizeStage(lemmatizer), nil
+}
+
+// Creates a stage lemmatizing tokens, except placeholders.
+func LemmatizeStage(lemmatizer *Lemmatizer) Stage {
+	return stageFunc(func(tokens []string, stopWords map[string]struct{}) []string {
+		for i, token := range tokens {
+			if !isPlaceholder(token) {
+				tokens[i] = lemmatizer.Lemma(token)
+			}
+		}
+		return tokens
+	})
+}
+
+// Stems tokens with the Snowball English stemmer, except placeholders.
+func stemStage(tokens []string, stopWords map[string]struct{}) []string {
+	for i, token := range tokens {
+		if !isPlaceholder(token) {
+			tokens[i] = Stem(token)
+		}
+	}
+	return tokens
+}
+
+func newNGramsStage(config StageConfig) (Stage, error) {
+	if config.N < 1 || config.N > MAX_NGRAMS {
+		return nil, fmt.Errorf("n of %s must be between 1 and %d, got %d", STAGE_NGRAMS, MAX_NGRAMS, config.N)
+	}
+	return stageFunc(func(tokens []string, stopWords map[string]struct{}) []string {
+		return append(tokens, nGrams(tokens, config.N)...)
+	}), nil
+}
@@ -0,0 +1,136 @@
+package util
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestPipelineDefaultStages(t *testing.T) {
+	texts := []string{"Late fees charged on my card", "Mortgage escrow is WRONG"}
+	stopWords := map[string]struct{}{"on": {}, "my": {}, "is": {}}
+
+	for _, config := range []TokenizerConfig{{}, {NGrams: 2}, {Normalize: true}, {Morphology: STAGE_STEM}} {
+		explicit := TokenizerConfig{Stages: config.PipelineStages()}
+		if result, expected := newTestTokenizer(t, explicit).Tokenize(texts, stopWords), newTestTokenizer(t, config).Tokenize(texts, stopWords); !reflect.DeepEqual(result, expected) {
+			t.Errorf("Expected declared default stages of %+v to tokenize the same: got %v, want %v", config, result, expected)
+		}
+	}
+}
+
+func TestPipelineCustomStages(t *testing.T) {
+	config := TokenizerConfig{Stages: []StageConfig{{Type: STAGE_SPLIT}, {Type: STAGE_LOWERCASE}, {Type: STAGE_NGRAMS, N: 2}}}
+	result := newTestTokenizer(t, config).Tokenize([]string{"Late fees"}, map[string]struct{}{"late": {}})
+	expected := []string{"late", "fees", "late fees"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+}
+
+type upperStage struct{}
+
+func (upperStage) Process(tokens []string, stopWords map[string]struct{}) []string {
+	for i, token := range tokens {
+		tokens[i] = strings.ToUpper(token)
+	}
+	return tokens
+}
+
+func TestRegisterStage(t *testing.T) {
+	RegisterStage("test_upper", func(config StageConfig) (Stage, error) { return upperStage{}, nil })
+	defer delete(stageFactories, "test_upper")
+
+	pipeline, err := NewPipeline([]StageConfig{{Type: STAGE_SPLIT}, {Type: "test_upper"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	result := pipeline.Process("late fee", nil)
+	expected := []string{"LATE", "FEE"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+}
+
+// Run with -race to check that stages can be registered while pipelines are created.
+func TestRegisterStageConcurrent(t *testing.T) {
+	defer func() {
+		stageFactoriesMu.Lock()
+		defer stageFactoriesMu.Unlock()
+		for i := 0; i < 10; i++ {
+			delete(stageFactories, fmt.Sprintf("test_upper_%d", i))
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			RegisterStage(fmt.Sprintf("test_upper_%d", i), func(config StageConfig) (Stage, error) { return upperStage{}, nil })
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := NewPipeline([]StageConfig{{Type: STAGE_SPLIT}}); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if _, err := NewPipeline([]StageConfig{{Type: "test_upper_9"}}); err != nil {
+		t.Errorf("Expected every registered stage to be available: %v", err)
+	}
+}
+
+func TestNewPipelineInvalidStages(t *testing.T) {
+	invalid := [][]StageConfig{
+		{{Type: "unknown"}},
+		{{Type: STAGE_SPLIT}, {Type: STAGE_NGRAMS}},
+		{{Type: STAGE_NGRAMS, N: MAX_NGRAMS + 1}},
+	}
+	for _, stages := range invalid {
+		if _, err := NewPipeline(stages); err == nil {
+			t.Errorf("Expected error creating pipeline of %+v", stages)
+		}
+	}
+}
+
+func TestTokenizeMorphology(t *testing.T) {
+	result := newTestTokenizer(t, TokenizerConfig{Morphology: STAGE_STEM}).Tokenize([]string{"Overdrafted accounts"}, map[string]struct{}{})
+	expected := []string{"overdraft", "account"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+
+	if err := (TokenizerConfig{Morphology: "unknown"}).Validate(); err == nil {
+		t.Errorf("Expected error validating unknown morphology")
+	}
+}
+
+func TestValidateStagesWithOptions(t *testing.T) {
+	config := TokenizerConfig{NGrams: 2, Stages: []StageConfig{{Type: STAGE_SPLIT}}}
+	if err := config.Validate(); err == nil {
+		t.Errorf("Expected error validating stages together with ngrams")
+	}
+}
+
+func TestReadTokenizerConfigStages(t *testing.T) {
+	err := ioutil.WriteFile("test.json", []byte(`{"ngrams": 1, "stages": [{"type": "split"}, {"type": "lowercase"}, {"type": "ngrams", "n": 3}]}`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test file: %v", err)
+	}
+	defer os.Remove("test.json")
+
+	result, err := ReadTokenizerConfig("test.json")
+	if err != nil {
+		t.Fatalf("Error reading tokenizer config: %v", err)
+	}
+	expected := []StageConfig{{Type: STAGE_SPLIT}, {Type: STAGE_LOWERCASE}, {Type: STAGE_NGRAMS, N: 3}}
+	if !reflect.DeepEqual(result.Stages, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result.Stages, expected)
+	}
+}
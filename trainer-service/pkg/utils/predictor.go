@@ -0,0 +1,87 @@
+package util
+
+import (
+	"math"
+	"sort"
+
+	"github.com/navossoc/bayesian"
+)
+
+type Prediction struct {
+	Class       string  `json:"class"`
+	Probability float64 `json:"probability"`
+	LogScore    float64 `json:"log_score"`
+}
+
+// Predicts classes of support tickets with a trained classifier, tokenizing
+// texts with the same stop words and tokenizer that were used for training.
+type Predictor struct {
+	classifier *bayesian.Classifier
+	stopWords  map[string]struct{}
+	tokenizer  *Tokenizer
+}
+
+func NewPredictor(classifier *bayesian.Classifier, stopWords map[string]struct{}, tokenizer *Tokenizer) *Predictor {
+	return &Predictor{classifier: classifier, stopWords: stopWords, tokenizer: tokenizer}
+}
+
+// Returns classes the predictor can choose from, in the classifier order.
+func (p *Predictor) Classes() []string {
+	classes := make([]string, len(p.classifier.Classes))
+	for i, class := range p.classifier.Classes {
+		classes[i] = string(class)
+	}
+	return classes
+}
+
+// Returns every class ranked from the most to the least likely one.
+func (p *Predictor) Predict(text string) []Prediction {
+	return p.PredictTopK(text, 0)
+}
+
+// Returns k most likely classes. Non positive k returns all classes.
+func (p *Predictor) PredictTopK(text string, k int) []Prediction {
+	tokens := p.tokenizer.Tokenize([]string{text}, p.stopWords)
+	logScores, _, _ := p.classifier.LogScores(tokens)
+	probs := softmax(logScores)
+
+	predictions := make([]Prediction, len(logScores))
+	for i, class := range p.classifier.Classes {
+		predictions[i] = Prediction{Class: string(class), Probability: probs[i], LogScore: logScores[i]}
+	}
+
+	sort.SliceStable(predictions, func(i, j int) bool {
+		return predictions[i].LogScore > predictions[j].LogScore
+	})
+
+	if k > 0 && k < len(predictions) {
+		predictions = predictions[:k]
+	}
+	return predictions
+}
+
+// Converts log scores to probabilities. Unlike bayesian ProbScores it does not
+// underflow on long documents, because scores are shifted by their maximum first.
+func softmax(logScores []float64) []float64 {
+	probs := make([]float64, len(logScores))
+	if len(logScores) == 0 {
+		return probs
+	}
+
+	max := logScores[0]
+	for _, s := range logScores {
+		if s > max {
+			max = s
+		}
+	}
+
+	sum := 0.0
+	for i, s := range logScores {
+		probs[i] = math.Exp(s - max)
+		sum += probs[i]
+	}
+	for i := range probs {
+		probs[i] /= sum
+	}
+	return probs
+}
@@ -0,0 +1,96 @@
+package util
+
+import (
+	"math"
+	"reflect"
+	"testing"
+
+	"github.com/navossoc/bayesian"
+)
+
+func newTestPredictor(t *testing.T) *Predictor {
+	stopWords := map[string]struct{}{
+		"the": {},
+		"is":  {},
+	}
+	classifier := bayesian.NewClassifier(bayesian.Class("class1"), bayesian.Class("class2"), bayesian.Class("class3"))
+	classifier.Learn([]string{"this", "is", "a", "text"}, bayesian.Class("class1"))
+	classifier.Learn([]string{"this", "is", "another", "text"}, bayesian.Class("class2"))
+	classifier.Learn([]string{"yet", "another", "text"}, bayesian.Class("class3"))
+	return NewPredictor(classifier, stopWords, newTestTokenizer(t, TokenizerConfig{}))
+}
+
+func TestPredict(t *testing.T) {
+	predictor := newTestPredictor(t)
+
+	t.Run("Correct classification for class1", func(t *testing.T) {
+		result := predictor.Predict("this is a text")
+		if result[0].Class != "class1" {
+			t.Errorf("Expected class1, got %s", result[0].Class)
+		}
+		if result[0].Probability < 0.9 {
+			t.Errorf("Expected %f probability, to be more than 0.9", result[0].Probability)
+		}
+	})
+
+	t.Run("Correct classification for class2", func(t *testing.T) {
+		result := predictor.Predict("this is another text")
+		if result[0].Class != "class2" {
+			t.Errorf("Expected class2, got %s", result[0].Class)
+		}
+		if result[0].Probability < 0.9 {
+			t.Errorf("Expected %f probability, to be more than 0.9", result[0].Probability)
+		}
+	})
+}
+
+func TestPredictRanksAllClasses(t *testing.T) {
+	result := newTestPredictor(t).Predict("this is another text")
+
+	if len(result) != 3 {
+		t.Fatalf("Expected 3 predictions, got %d", len(result))
+	}
+
+	sum := 0.0
+	for i, p := range result {
+		sum += p.Probability
+		if i > 0 && result[i-1].LogScore < p.LogScore {
+			t.Errorf("Predictions are not ranked: %v", result)
+		}
+	}
+	if math.Abs(sum-1) > 1e-9 {
+		t.Errorf("Expected probabilities to sum up to 1, got %f", sum)
+	}
+	if result[1].Class != "class3" {
+		t.Errorf("Expected class3 to be second best, got %s", result[1].Class)
+	}
+}
+
+func TestPredictTopK(t *testing.T) {
+	predictor := newTestPredictor(t)
+
+	result := predictor.PredictTopK("this is another text", 2)
+	if len(result) != 2 {
+		t.Errorf("Expected 2 predictions, got %d", len(result))
+	}
+
+	result = predictor.PredictTopK("this is another text", 10)
+	if len(result) != 3 {
+		t.Errorf("Expected all 3 predictions when k is larger than number of classes, got %d", len(result))
+	}
+}
+
+func TestPredictorClasses(t *testing.T) {
+	result := newTestPredictor(t).Classes()
+	expected := []string{"class1", "class2", "class3"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+}
+
+func TestSoftmaxDoesNotUnderflow(t *testing.T) {
+	result := softmax([]float64{-5000, -5001})
+	if math.IsNaN(result[0]) || result[0] <= result[1] {
+		t.Errorf("Expected finite ranked probabilities, got %v", result)
+	}
+}
@@ -0,0 +1,443 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const (
+	REGISTRY_VERSIONS_DIR = "versions"
+	REGISTRY_STATE_FILE   = "registry.json"
+	REGISTRY_MODEL_FILE   = "model.zip"
+	REGISTRY_LOCK_FILE    = "registry.lock"
+
+	// How long to wait for another command to release the registry lock, and how often to check.
+	REGISTRY_LOCK_TIMEOUT = 10 * time.Second
+	REGISTRY_LOCK_RETRY   = 50 * time.Millisecond
+
+	// References resolved by the registry besides version ids.
+	VERSION_PRODUCTION = "production"
+	VERSION_LATEST     = "latest"
+
+	VERSION_PREFIX = "v"
+)
+
+var ErrVersionNotFound = errors.New("model version not found")
+
+// Stores every trained model as an immutable version in a directory:
+//
+//	<dir>/versions/v1/model.zip
+//	<dir>/versions/v2/model.zip
+//	<dir>/registry.json
+//
+// registry.json records which version is in production and the versions promoted before it.
+type ModelRegistry struct {
+	Dir string
+}
+
+type ModelVersion struct {
+	ID         string
+	Path       string
+	Manifest   ModelManifest
+	Production bool
+}
+
+type Promotion struct {
+	Version    string    `json:"version"`
+	PromotedAt time.Time `json:"promoted_at"`
+}
+
+type registryState struct {
+	Production string `json:"production"`
+	// Promotions in order, the last one is the production version.
+	History []Promotion `json:"history"`
+}
+
+func NewModelRegistry(dir string) *ModelRegistry {
+	return &ModelRegistry{Dir: dir}
+}
+
+// Stores the bundle as a new version. Versions are numbered in order of registration
+// and their files are never replaced.
+func (r *ModelRegistry) Register(bundle *ModelBundle) (ModelVersion, error) {
+	versionsDir := filepath.Join(r.Dir, REGISTRY_VERSIONS_DIR)
+	if err := os.MkdirAll(versionsDir, 0777); err != nil {
+		return ModelVersion{}, err
+	}
+
+	unlock, err := r.lock()
+	if err != nil {
+		return ModelVersion{}, err
+	}
+	ids, err := r.versionIDs()
+	if err != nil {
+		unlock()
+		return ModelVersion{}, err
+	}
+	next := 1
+	if len(ids) > 0 {
+		next = versionNumber(ids[len(ids)-1]) + 1
+	}
+
+	// Creating the directory claims the version, so concurrent trainings never share one.
+	var id string
+	for {
+		id = VERSION_PREFIX + strconv.Itoa(next)
+		err := os.Mkdir(filepath.Join(versionsDir, id), 0777)
+		if err == nil {
+			break
+		}
+		if !os.IsExist(err) {
+			unlock()
+			return ModelVersion{}, err
+		}
+		next++
+	}
+	// The bundle is written without the lock, the claimed directory keeps the version.
+	unlock()
+
+	path := r.modelPath(id)
+	if err := WriteModelBundle(path, bundle); err != nil {
+		os.RemoveAll(filepath.Dir(path))
+		return ModelVersion{}, err
+	}
+	if err := os.Chmod(path, 0444); err != nil {
+		return ModelVersion{}, err
+	}
+	return ModelVersion{ID: id, Path: path, Manifest: bundle.Manifest}, nil
+}
+
+// Returns stored versions in order of registration.
+func (r *ModelRegistry) Versions() ([]ModelVersion, error) {
+	ids, err := r.versionIDs()
+	if err != nil {
+		return nil, err
+	}
+	state, err := r.readState()
+	if err != nil {
+		return nil, err
+	}
+
+	versions := make([]ModelVersion, 0, len(ids))
+	for _, id := range ids {
+		version, err := r.version(id, state)
+		if err != nil {
+			return nil, err
+		}
+		versions = append(versions, version)
+	}
+	return versions, nil
+}
+
+// Returns the version with the id, the production version for "production"
+// or the last registered version for "latest".
+func (r *ModelRegistry) Version(ref string) (ModelVersion, error) {
+	state, err := r.readState()
+	if err != nil {
+		return ModelVersion{}, err
+	}
+
+	id := ref
+	switch ref {
+	case VERSION_PRODUCTION:
+		if state.Production == "" {
+			return ModelVersion{}, fmt.Errorf("%w: no version is promoted to production in %s", ErrVersionNotFound, r.Dir)
+		}
+		id = state.Production
+	case VERSION_LATEST:
+		ids, err := r.versionIDs()
+		if err != nil {
+			return ModelVersion{}, err
+		}
+		if len(ids) == 0 {
+			return ModelVersion{}, fmt.Errorf("%w: registry %s is empty", ErrVersionNotFound, r.Dir)
+		}
+		id = ids[len(ids)-1]
+	}
+	return r.version(id, state)
+}
+
+// Returns the model file of the version, see Version for references.
+func (r *ModelRegistry) Resolve(ref string) (string, error) {
+	version, err := r.Version(ref)
+	if err != nil {
+		return "", err
+	}
+	return version.Path, nil
+}
+
+// Makes the version the production one. The model is loaded first, so a broken
+// or incompatible version is never promoted.
+func (r *ModelRegistry) Promote(ref string) (ModelVersion, error) {
+	version, err := r.Version(ref)
+	if err != nil {
+		return version, err
+	}
+	if _, err := ReadModelBundle(version.Path); err != nil {
+		return version, err
+	}
+
+	unlock, err := r.lock()
+	if err != nil {
+		return version, err
+	}
+	defer unlock()
+	state, err := r.readState()
+	if err != nil {
+		return version, err
+	}
+	if state.Production == version.ID {
+		return version, fmt.Errorf("%s is already in production", version.ID)
+	}
+	state.History = append(state.History, Promotion{Version: version.ID, PromotedAt: time.Now().UTC()})
+	state.Production = version.ID
+	version.Production = true
+	return version, r.writeState(state)
+}
+
+// Returns production to the version promoted before the current one.
+func (r *ModelRegistry) Rollback() (ModelVersion, error) {
+	unlock, err := r.lock()
+	if err != nil {
+		return ModelVersion{}, err
+	}
+	defer unlock()
+	state, err := r.readState()
+	if err != nil {
+		return ModelVersion{}, err
+	}
+	if len(state.History) < 2 {
+		return ModelVersion{}, fmt.Errorf("%w: there is no earlier production version to roll back to", ErrVersionNotFound)
+	}
+
+	state.History = state.History[:len(state.History)-1]
+	state.Production = state.History[len(state.History)-1].Version
+	version, err := r.version(state.Production, state)
+	if err != nil {
+		return version, err
+	}
+	return version, r.writeState(state)
+}
+
+func (r *ModelRegistry) version(id string, state registryState) (ModelVersion, error) {
+	if versionNumber(id) == 0 {
+		return ModelVersion{}, fmt.Errorf("%w: %q is not a version id", ErrVersionNotFound, id)
+	}
+	path := r.modelPath(id)
+	if _, err := os.Stat(path); err != nil {
+		return ModelVersion{}, fmt.Errorf("%w: %s", ErrVersionNotFound, id)
+	}
+
+	manifest, err := ReadModelManifest(path)
+	if err != nil {
+		return ModelVersion{}, err
+	}
+	return ModelVersion{ID: id, Path: path, Manifest: manifest, Production: id == state.Production}, nil
+}
+
+func (r *ModelRegistry) modelPath(id string) string {
+	return filepath.Join(r.Dir, REGISTRY_VERSIONS_DIR, id, REGISTRY_MODEL_FILE)
+}
+
+// Returns ids of versions with a model file, sorted by version number.
+func (r *ModelRegistry) versionIDs() ([]string, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(r.Dir, REGISTRY_VERSIONS_DIR))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	ids := []string{}
+	for _, entry := range entries {
+		id := entry.Name()
+		if !entry.IsDir() || versionNumber(id) == 0 {
+			continue
+		}
+		// Versions being written have no model file yet.
+		if _, err := os.Stat(r.modelPath(id)); err != nil {
+			continue
+		}
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return versionNumber(ids[i]) < versionNumber(ids[j])
+	})
+	return ids, nil
+}
+
+// Returns the number of the version id, 0 when it is not a version id.
+func versionNumber(id string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(id, VERSION_PREFIX))
+	if err != nil || n < 1 || !strings.HasPrefix(id, VERSION_PREFIX) {
+		return 0
+	}
+	return n
+}
+
+// Takes the lock of the registry by creating the lock file exclusively, so concurrent commands
+// never lose each other's changes of the registry state. Returns the function releasing it.
+// A lock file left by a command that crashed has to be removed by hand.
+func (r *ModelRegistry) lock() (func(), error) {
+	if err := os.MkdirAll(r.Dir, 0777); err != nil {
+		return nil, err
+	}
+
+	path := filepath.Join(r.Dir, REGISTRY_LOCK_FILE)
+	deadline := time.Now().Add(REGISTRY_LOCK_TIMEOUT)
+	for {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0666)
+		if err == nil {
+			file.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("registry %s is locked by another command, remove %s if none is running", r.Dir, path)
+		}
+		time.Sleep(REGISTRY_LOCK_RETRY)
+	}
+}
+
+func (r *ModelRegistry) readState() (registryState, error) {
+	var state registryState
+	bytes, err := ioutil.ReadFile(filepath.Join(r.Dir, REGISTRY_STATE_FILE))
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return state, err
+	}
+	if err := json.Unmarshal(bytes, &state); err != nil {
+		return state, fmt.Errorf("can not parse %s of registry %s: %w", REGISTRY_STATE_FILE, r.Dir, err)
+	}
+	return state, nil
+}
+
+func (r *ModelRegistry) writeState(state registryState) error {
+	bytes, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	err = writeFileAtomic(filepath.Join(r.Dir, REGISTRY_STATE_FILE), 0666, func(w io.Writer) error {
+		_, err := w.Write(bytes)
+		return err
+	})
+	return err
+}
+
+// Differences between two model versions.
+type ModelDiff struct {
+	From           string
+	To             string
+	AddedClasses   []string
+	RemovedClasses []string
+	// Training inputs and settings that changed, with their values in both versions.
+	Changes []ModelChange
+	// Evaluation reports of the versions, nil when a version has none.
+	FromMetrics *EvaluationReport
+	ToMetrics   *EvaluationReport
+}
+
+type ModelChange struct {
+	Name string
+	From string
+	To   string
+}
+
+func DiffModelVersions(from ModelVersion, to ModelVersion) *ModelDiff {
+	diff := &ModelDiff{From: from.ID, To: to.ID}
+	diff.AddedClasses = missingClasses(to.Manifest.Classes, from.Manifest.Classes)
+	diff.RemovedClasses = missingClasses(from.Manifest.Classes, to.Manifest.Classes)
+
+	a, b := from.Manifest, to.Manifest
+	fields := []struct {
+		name     string
+		from, to interface{}
+	}{
+		{"kind", a.Kind, b.Kind},
+		{"data", a.Fingerprint.Data, b.Fingerprint.Data},
+		{"stop words", a.Fingerprint.StopWords, b.Fingerprint.StopWords},
+		{"tokenizer", a.Fingerprint.Tokenizer, b.Fingerprint.Tokenizer},
+		{"mapping", a.Mapping, b.Mapping},
+		{"trainer version", a.Fingerprint.Version, b.Fingerprint.Version},
+	}
+	for _, f := range fields {
+		if !reflect.DeepEqual(f.from, f.to) {
+			diff.Changes = append(diff.Changes, ModelChange{Name: f.name, From: fmt.Sprintf("%+v", f.from), To: fmt.Sprintf("%+v", f.to)})
+		}
+	}
+
+	diff.FromMetrics, _ = a.EvaluationReport()
+	diff.ToMetrics, _ = b.EvaluationReport()
+	return diff
+}
+
+func (d *ModelDiff) String() string {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintf(w, "%s -> %s\n", d.From, d.To)
+	if len(d.AddedClasses) > 0 {
+		fmt.Fprintf(w, "Added classes:\t%s\n", strings.Join(d.AddedClasses, ", "))
+	}
+	if len(d.RemovedClasses) > 0 {
+		fmt.Fprintf(w, "Removed classes:\t%s\n", strings.Join(d.RemovedClasses, ", "))
+	}
+	for _, c := range d.Changes {
+		fmt.Fprintf(w, "Changed %s:\t%s -> %s\n", c.Name, c.From, c.To)
+	}
+	if len(d.AddedClasses) == 0 && len(d.RemovedClasses) == 0 && len(d.Changes) == 0 {
+		fmt.Fprintln(w, "Same classes and training inputs")
+	}
+
+	if d.FromMetrics == nil || d.ToMetrics == nil {
+		fmt.Fprintln(w, "Metrics:\tnot available for both versions")
+		w.Flush()
+		return buf.String()
+	}
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Metric\t%s\t%s\tChange\n", d.From, d.To)
+	fmt.Fprintf(w, "Accuracy\t%.4f\t%.4f\t%+.4f\n", d.FromMetrics.Accuracy, d.ToMetrics.Accuracy, d.ToMetrics.Accuracy-d.FromMetrics.Accuracy)
+	fmt.Fprintf(w, "Macro F1\t%.4f\t%.4f\t%+.4f\n", d.FromMetrics.MacroF1, d.ToMetrics.MacroF1, d.ToMetrics.MacroF1-d.FromMetrics.MacroF1)
+	for _, class := range d.ToMetrics.Classes {
+		fromClass, ok := d.FromMetrics.PerClass[class]
+		if !ok {
+			continue
+		}
+		toClass := d.ToMetrics.PerClass[class]
+		fmt.Fprintf(w, "F1 %s\t%.4f\t%.4f\t%+.4f\n", class, fromClass.F1, toClass.F1, toClass.F1-fromClass.F1)
+	}
+	w.Flush()
+	return buf.String()
+}
+
+// Returns classes that are not in the other list.
+func missingClasses(classes []string, other []string) []string {
+	known := make(map[string]struct{}, len(other))
+	for _, class := range other {
+		known[class] = struct{}{}
+	}
+	var missing []string
+	for _, class := range classes {
+		if _, ok := known[class]; !ok {
+			missing = append(missing, class)
+		}
+	}
+	return missing
+}
@@ -0,0 +1,199 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func registerTestVersions(t *testing.T, registry *ModelRegistry, count int) {
+	for i := 0; i < count; i++ {
+		if _, err := registry.Register(newTestBundle()); err != nil {
+			t.Fatalf("Error registering model: %v", err)
+		}
+	}
+}
+
+func TestRegistryRegister(t *testing.T) {
+	registry := NewModelRegistry("test_dir")
+	defer os.RemoveAll("test_dir")
+	registerTestVersions(t, registry, 2)
+
+	versions, err := registry.Versions()
+	if err != nil {
+		t.Fatalf("Error listing versions: %v", err)
+	}
+	if len(versions) != 2 || versions[0].ID != "v1" || versions[1].ID != "v2" {
+		t.Fatalf("Expected versions v1 and v2, got %+v", versions)
+	}
+	if versions[0].Production || versions[0].Manifest.Kind != MODEL_KIND_FLAT {
+		t.Errorf("Unexpected version: %+v", versions[0])
+	}
+
+	if _, err := ReadModelBundle(versions[1].Path); err != nil {
+		t.Errorf("Error reading registered model: %v", err)
+	}
+	if info, err := os.Stat(versions[1].Path); err != nil || info.Mode().Perm()&0222 != 0 {
+		t.Errorf("Expected registered model to be read-only, got %v", info.Mode())
+	}
+
+	latest, err := registry.Version(VERSION_LATEST)
+	if err != nil || latest.ID != "v2" {
+		t.Errorf("Expected latest version v2, got %+v, %v", latest, err)
+	}
+}
+
+func TestRegistryRegisterSkipsClaimedVersions(t *testing.T) {
+	registry := NewModelRegistry("test_dir")
+	defer os.RemoveAll("test_dir")
+	if err := os.MkdirAll("test_dir/versions/v1", 0777); err != nil {
+		t.Fatalf("Error creating version directory: %v", err)
+	}
+
+	version, err := registry.Register(newTestBundle())
+	if err != nil {
+		t.Fatalf("Error registering model: %v", err)
+	}
+	if version.ID != "v2" {
+		t.Errorf("Expected version being written by another training to be skipped, got %s", version.ID)
+	}
+}
+
+func TestRegistryPromoteAndRollback(t *testing.T) {
+	registry := NewModelRegistry("test_dir")
+	defer os.RemoveAll("test_dir")
+	registerTestVersions(t, registry, 3)
+
+	if _, err := registry.Resolve(VERSION_PRODUCTION); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("Expected no production version before promotion, got %v", err)
+	}
+
+	for _, id := range []string{"v1", "v3"} {
+		if _, err := registry.Promote(id); err != nil {
+			t.Fatalf("Error promoting %s: %v", id, err)
+		}
+	}
+	if _, err := registry.Promote("v3"); err == nil {
+		t.Errorf("Expected error promoting the production version again")
+	}
+	production, err := registry.Version(VERSION_PRODUCTION)
+	if err != nil || production.ID != "v3" || !production.Production {
+		t.Errorf("Expected v3 in production, got %+v, %v", production, err)
+	}
+
+	version, err := registry.Rollback()
+	if err != nil || version.ID != "v1" {
+		t.Fatalf("Expected rollback to v1, got %+v, %v", version, err)
+	}
+	path, err := registry.Resolve(VERSION_PRODUCTION)
+	if err != nil || path != registry.modelPath("v1") {
+		t.Errorf("Test case failed: got %v, want %v", path, registry.modelPath("v1"))
+	}
+	if _, err := registry.Rollback(); !errors.Is(err, ErrVersionNotFound) {
+		t.Errorf("Expected error rolling back the first promoted version, got %v", err)
+	}
+}
+
+// Run with -race to check that concurrent promotions are recorded without losing any of them.
+func TestRegistryPromoteConcurrent(t *testing.T) {
+	registry := NewModelRegistry("test_dir")
+	defer os.RemoveAll("test_dir")
+	registerTestVersions(t, registry, 8)
+
+	var wg sync.WaitGroup
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(id string) {
+			defer wg.Done()
+			if _, err := registry.Promote(id); err != nil {
+				t.Errorf("Error promoting %s: %v", id, err)
+			}
+		}(fmt.Sprintf("v%d", i))
+	}
+	wg.Wait()
+
+	state, err := registry.readState()
+	if err != nil {
+		t.Fatalf("Error reading registry state: %v", err)
+	}
+	if len(state.History) != 8 {
+		t.Errorf("Expected every promotion to be recorded, got %v", state.History)
+	}
+	if _, err := os.Stat("test_dir/" + REGISTRY_LOCK_FILE); !os.IsNotExist(err) {
+		t.Errorf("Expected registry lock to be released, got %v", err)
+	}
+}
+
+func TestRegistryPromoteLocked(t *testing.T) {
+	registry := NewModelRegistry("test_dir")
+	defer os.RemoveAll("test_dir")
+	registerTestVersions(t, registry, 1)
+
+	unlock, err := registry.lock()
+	if err != nil {
+		t.Fatalf("Error locking registry: %v", err)
+	}
+	go func() {
+		time.Sleep(2 * REGISTRY_LOCK_RETRY)
+		unlock()
+	}()
+	if _, err := registry.Promote("v1"); err != nil {
+		t.Errorf("Expected promotion to wait for the lock, got %v", err)
+	}
+}
+
+func TestRegistryUnknownVersion(t *testing.T) {
+	registry := NewModelRegistry("test_dir")
+	defer os.RemoveAll("test_dir")
+	registerTestVersions(t, registry, 1)
+
+	for _, ref := range []string{"v2", "model", "../v1"} {
+		if _, err := registry.Promote(ref); !errors.Is(err, ErrVersionNotFound) {
+			t.Errorf("Expected %q not to be found, got %v", ref, err)
+		}
+	}
+}
+
+func TestDiffModelVersions(t *testing.T) {
+	from := ModelVersion{ID: "v1", Manifest: ModelManifest{
+		Kind:        MODEL_KIND_FLAT,
+		Classes:     []string{"class1", "class2"},
+		Fingerprint: ModelFingerprint{Data: "a", Tokenizer: TokenizerConfig{}},
+	}}
+	to := ModelVersion{ID: "v2", Manifest: ModelManifest{
+		Kind:        MODEL_KIND_FLAT,
+		Classes:     []string{"class2", "class3"},
+		Fingerprint: ModelFingerprint{Data: "b", Tokenizer: TokenizerConfig{NGrams: 2}},
+	}}
+	fromReport := reportFromConfusionMatrix([]string{"class1", "class2"}, [][]int{{1, 1}, {0, 2}})
+	toReport := reportFromConfusionMatrix([]string{"class2", "class3"}, [][]int{{2, 0}, {0, 2}})
+	from.Manifest.Metrics, _ = json.Marshal(fromReport)
+	to.Manifest.Metrics, _ = json.Marshal(toReport)
+
+	diff := DiffModelVersions(from, to)
+	if len(diff.AddedClasses) != 1 || diff.AddedClasses[0] != "class3" || len(diff.RemovedClasses) != 1 || diff.RemovedClasses[0] != "class1" {
+		t.Errorf("Unexpected class changes: added %v, removed %v", diff.AddedClasses, diff.RemovedClasses)
+	}
+	changed := []string{}
+	for _, c := range diff.Changes {
+		changed = append(changed, c.Name)
+	}
+	if strings.Join(changed, ",") != "data,tokenizer" {
+		t.Errorf("Test case failed: got %v, want %v", changed, []string{"data", "tokenizer"})
+	}
+	if diff.FromMetrics == nil || diff.ToMetrics == nil {
+		t.Fatalf("Expected metrics of both versions")
+	}
+
+	text := diff.String()
+	for _, expected := range []string{"v1 -> v2", "Added classes:", "Macro F1", "F1 class2"} {
+		if !strings.Contains(text, expected) {
+			t.Errorf("Expected diff to contain %q, got %s", expected, text)
+		}
+	}
+}
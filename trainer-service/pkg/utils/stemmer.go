@@ -0,0 +1,296 @@
+package util
+
+import "strings"
+
+// Snowball English (Porter2) stemmer, see https://snowballstem.org/algorithms/english/stemmer.html.
+// Unlike Lemmatize it needs no dictionary and reduces unknown domain words,
+// e.g. "overdrafted" and "refinanced", but stems are not always real words.
+
+var stemExceptions = map[string]string{
+	"skis": "ski", "skies": "sky", "dying": "die", "lying": "lie", "tying": "tie",
+	"idly": "idl", "gently": "gentl", "ugly": "ugli", "early": "earli", "only": "onli", "singly": "singl",
+	"sky": "sky", "news": "news", "howe": "howe", "atlas": "atlas", "cosmos": "cosmos", "bias": "bias", "andes": "andes",
+}
+
+// Words left unchanged after step 1a.
+var stemExceptionsAfterStep1a = map[string]struct{}{
+	"inning": {}, "outing": {}, "canning": {}, "herring": {}, "earring": {},
+	"proceed": {}, "exceed": {}, "succeed": {},
+}
+
+var stemStep2Suffixes = []struct{ suffix, replacement string }{
+	{"ization", "ize"}, {"ational", "ate"}, {"fulness", "ful"}, {"ousness", "ous"}, {"iveness", "ive"},
+	{"tional", "tion"}, {"biliti", "ble"}, {"lessli", "less"},
+	{"entli", "ent"}, {"ation", "ate"}, {"alism", "al"}, {"aliti", "al"}, {"ousli", "ous"}, {"iviti", "ive"}, {"fulli", "ful"},
+	{"enci", "ence"}, {"anci", "ance"}, {"abli", "able"}, {"izer", "ize"}, {"ator", "ate"}, {"alli", "al"},
+	{"bli", "ble"}, {"ogi", "og"}, {"li", ""},
+}
+
+var stemStep3Suffixes = []struct{ suffix, replacement string }{
+	{"ational", "ate"}, {"tional", "tion"}, {"alize", "al"}, {"icate", "ic"}, {"iciti", "ic"},
+	{"ative", ""}, {"ical", "ic"}, {"ness", ""}, {"ful", ""},
+}
+
+var stemStep4Suffixes = []string{
+	"ement", "ance", "ence", "able", "ible", "ment", "ant", "ent", "ism", "ate", "iti", "ous", "ive", "ize", "ion", "al", "er", "ic",
+}
+
+// Stems a lower cased English word. Words with other characters than ASCII letters are returned unchanged.
+func Stem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i++ {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+	if stem, ok := stemExceptions[word]; ok {
+		return stem
+	}
+
+	w := []byte(word)
+	for i := range w {
+		if w[i] == 'y' && (i == 0 || isStemVowel(w[i-1])) {
+			w[i] = 'Y'
+		}
+	}
+	s := &stemmer{word: w}
+	s.r1, s.r2 = stemRegions(w)
+
+	s.step1a()
+	if _, ok := stemExceptionsAfterStep1a[string(s.word)]; ok {
+		return string(s.word)
+	}
+	s.step1b()
+	s.step1c()
+	s.step2()
+	s.step3()
+	s.step4()
+	s.step5()
+
+	return strings.ToLower(string(s.word))
+}
+
+type stemmer struct {
+	word []byte
+	// Starts of R1 and R2 regions.
+	r1, r2 int
+}
+
+func isStemVowel(c byte) bool {
+	switch c {
+	case 'a', 'e', 'i', 'o', 'u', 'y':
+		return true
+	}
+	return false
+}
+
+// R1 starts after the first non-vowel following a vowel, R2 is R1 of R1.
+func stemRegions(w []byte) (int, int) {
+	r1 := len(w)
+	for _, prefix := range []string{"gener", "commun", "arsen"} {
+		if strings.HasPrefix(string(w), prefix) {
+			r1 = len(prefix)
+			break
+		}
+	}
+	if r1 == len(w) {
+		r1 = nextStemRegion(w, 0)
+	}
+	return r1, nextStemRegion(w, r1)
+}
+
+func nextStemRegion(w []byte, start int) int {
+	for i := start + 1; i < len(w); i++ {
+		if !isStemVowel(w[i]) && isStemVowel(w[i-1]) {
+			return i + 1
+		}
+	}
+	return len(w)
+}
+
+func (s *stemmer) hasSuffix(suffix string) bool {
+	return strings.HasSuffix(string(s.word), suffix)
+}
+
+// Whether the suffix, which the word ends with, is in the region starting at start.
+func (s *stemmer) inRegion(suffix string, start int) bool {
+	return len(s.word)-len(suffix) >= start
+}
+
+func (s *stemmer) replace(suffix string, replacement string) {
+	s.word = append(s.word[:len(s.word)-len(suffix)], replacement...)
+}
+
+func (s *stemmer) hasVowelBefore(end int) bool {
+	for i := 0; i < end; i++ {
+		if isStemVowel(s.word[i]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Whether the word ends with a vowel followed by a non-vowel other than w, x and Y and preceded
+// by a non-vowel, or is a vowel followed by a non-vowel.
+func (s *stemmer) endsWithShortSyllable() bool {
+	w := s.word
+	n := len(w)
+	if n == 2 {
+		return isStemVowel(w[0]) && !isStemVowel(w[1])
+	}
+	if n < 3 {
+		return false
+	}
+	last := w[n-1]
+	return !isStemVowel(w[n-3]) && isStemVowel(w[n-2]) && !isStemVowel(last) && last != 'w' && last != 'x' && last != 'Y'
+}
+
+func (s *stemmer) isShort() bool {
+	return s.r1 >= len(s.word) && s.endsWithShortSyllable()
+}
+
+func (s *stemmer) step1a() {
+	switch {
+	case s.hasSuffix("sses"):
+		s.replace("sses", "ss")
+	case s.hasSuffix("ied"), s.hasSuffix("ies"):
+		if len(s.word) > 4 {
+			s.replace("ies", "i")
+		} else {
+			s.replace("ies", "ie")
+		}
+	case s.hasSuffix("us"), s.hasSuffix("ss"):
+	case s.hasSuffix("s"):
+		if s.hasVowelBefore(len(s.word) - 2) {
+			s.replace("s", "")
+		}
+	}
+}
+
+func (s *stemmer) step1b() {
+	for _, suffix := range []string{"eedly", "eed"} {
+		if s.hasSuffix(suffix) {
+			if s.inRegion(suffix, s.r1) {
+				s.replace(suffix, "ee")
+			}
+			return
+		}
+	}
+
+	for _, suffix := range []string{"ingly", "edly", "ing", "ed"} {
+		if !s.hasSuffix(suffix) {
+			continue
+		}
+		if !s.hasVowelBefore(len(s.word) - len(suffix)) {
+			return
+		}
+		s.replace(suffix, "")
+
+		switch {
+		case s.hasSuffix("at"), s.hasSuffix("bl"), s.hasSuffix("iz"):
+			s.word = append(s.word, 'e')
+		case s.endsWithDouble():
+			s.word = s.word[:len(s.word)-1]
+		case s.isShort():
+			s.word = append(s.word, 'e')
+		}
+		return
+	}
+}
+
+func (s *stemmer) endsWithDouble() bool {
+	for _, double := range []string{"bb", "dd", "ff", "gg", "mm", "nn", "pp", "rr", "tt"} {
+		if s.hasSuffix(double) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *stemmer) step1c() {
+	n := len(s.word)
+	if n > 2 && (s.word[n-1] == 'y' || s.word[n-1] == 'Y') && !isStemVowel(s.word[n-2]) {
+		s.word[n-1] = 'i'
+	}
+}
+
+func (s *stemmer) step2() {
+	for _, r := range stemStep2Suffixes {
+		if !s.hasSuffix(r.suffix) {
+			continue
+		}
+		if !s.inRegion(r.suffix, s.r1) {
+			return
+		}
+		preceding := byte(0)
+		if n := len(s.word) - len(r.suffix); n > 0 {
+			preceding = s.word[n-1]
+		}
+		switch r.suffix {
+		case "ogi":
+			if preceding != 'l' {
+				return
+			}
+		case "li":
+			if !strings.ContainsRune("cdeghkmnrt", rune(preceding)) {
+				return
+			}
+		}
+		s.replace(r.suffix, r.replacement)
+		return
+	}
+}
+
+func (s *stemmer) step3() {
+	for _, r := range stemStep3Suffixes {
+		if !s.hasSuffix(r.suffix) {
+			continue
+		}
+		if !s.inRegion(r.suffix, s.r1) || (r.suffix == "ative" && !s.inRegion(r.suffix, s.r2)) {
+			return
+		}
+		s.replace(r.suffix, r.replacement)
+		return
+	}
+}
+
+func (s *stemmer) step4() {
+	for _, suffix := range stemStep4Suffixes {
+		if !s.hasSuffix(suffix) {
+			continue
+		}
+		if !s.inRegion(suffix, s.r2) {
+			return
+		}
+		if suffix == "ion" {
+			n := len(s.word) - len(suffix)
+			if n == 0 || (s.word[n-1] != 's' && s.word[n-1] != 't') {
+				return
+			}
+		}
+		s.replace(suffix, "")
+		return
+	}
+}
+
+func (s *stemmer) step5() {
+	switch {
+	case s.hasSuffix("e"):
+		if s.inRegion("e", s.r2) {
+			s.replace("e", "")
+			return
+		}
+		if s.inRegion("e", s.r1) {
+			s.word = s.word[:len(s.word)-1]
+			if s.endsWithShortSyllable() {
+				s.word = append(s.word, 'e')
+			}
+		}
+	case s.hasSuffix("l"):
+		if s.inRegion("l", s.r2) && len(s.word) > 1 && s.word[len(s.word)-2] == 'l' {
+			s.replace("l", "")
+		}
+	}
+}
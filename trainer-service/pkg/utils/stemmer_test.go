@@ -0,0 +1,38 @@
+package util
+
+import "testing"
+
+func TestStem(t *testing.T) {
+	tests := map[string]string{
+		"consigned":   "consign",
+		"consignment": "consign",
+		"generously":  "generous",
+		"happiness":   "happi",
+		"running":     "run",
+		"caresses":    "caress",
+		"ponies":      "poni",
+		"ties":        "tie",
+		"agreed":      "agre",
+		"relational":  "relat",
+		"generation":  "generat",
+		"skies":       "sky",
+		"succeeded":   "succeed",
+		"overdrafted": "overdraft",
+		"refinanced":  "refinanc",
+		"payments":    "payment",
+		"fee":         "fee",
+	}
+	for word, expected := range tests {
+		if result := Stem(word); result != expected {
+			t.Errorf("Test case %s failed: got %s, want %s", word, result, expected)
+		}
+	}
+}
+
+func TestStemNonLetters(t *testing.T) {
+	for _, word := range []string{"", "is", "café", "Fees", PLACEHOLDER_AMOUNT} {
+		if result := Stem(word); result != word {
+			t.Errorf("Expected %q to be unchanged, got %q", word, result)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package util
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"sort"
+	"strings"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+)
+
+// Global stop words with per class overrides. A class is trained without global stop
+// words, except the ones it keeps, and without its own stop words. Predictions remove
+// global stop words not kept by any class, as the class of a new text is not known.
+type StopWordLists struct {
+	global  map[string]struct{}
+	classes map[string]map[string]struct{}
+	// Global stop words for classes without overrides.
+	defaultClass map[string]struct{}
+	// Stop words of every class, which are not removed from texts to predict.
+	extra        map[string]map[string]struct{}
+	defaultExtra map[string]struct{}
+}
+
+// Creates stop word lists from global stop words and per class overrides.
+func NewStopWordLists(words map[string]struct{}, classes []models.StopWords) *StopWordLists {
+	// Stop words are matched against lowercased tokens, so they are lowercased like class words.
+	global := make(map[string]struct{}, len(words))
+	for word := range words {
+		global[strings.ToLower(word)] = struct{}{}
+	}
+
+	lists := &StopWordLists{
+		global:       make(map[string]struct{}, len(global)),
+		classes:      make(map[string]map[string]struct{}, len(classes)),
+		defaultClass: global,
+		extra:        make(map[string]map[string]struct{}, len(classes)),
+	}
+
+	kept := make(map[string]struct{})
+	for _, c := range classes {
+		for _, word := range c.Keep {
+			kept[strings.ToLower(word)] = struct{}{}
+		}
+	}
+	for word := range global {
+		if _, ok := kept[word]; !ok {
+			lists.global[word] = struct{}{}
+		}
+	}
+	lists.defaultExtra = lists.extraWords(global)
+
+	for _, c := range classes {
+		classWords := make(map[string]struct{}, len(global)+len(c.Texts))
+		for word := range global {
+			classWords[word] = struct{}{}
+		}
+		for _, word := range c.Keep {
+			delete(classWords, strings.ToLower(word))
+		}
+		for _, word := range c.Texts {
+			classWords[strings.ToLower(word)] = struct{}{}
+		}
+
+		lists.classes[c.Class] = classWords
+		lists.extra[c.Class] = lists.extraWords(classWords)
+	}
+	return lists
+}
+
+// Returns stop words not removed from texts to predict.
+func (l *StopWordLists) extraWords(stopWords map[string]struct{}) map[string]struct{} {
+	extra := make(map[string]struct{})
+	for word := range stopWords {
+		if _, ok := l.global[word]; !ok {
+			extra[word] = struct{}{}
+		}
+	}
+	return extra
+}
+
+// Reads stop words from a JSON file, either a flat array of global stop words or
+// an object with global stop words and per class overrides.
+func ReadStopWordLists(stopWordsDir string) (*StopWordLists, error) {
+	file, err := ReadStopWordsFile(stopWordsDir)
+	if err != nil {
+		return nil, err
+	}
+	return NewStopWordListsFromFile(file), nil
+}
+
+// Reads a stop words file, a flat array is read as global stop words.
+func ReadStopWordsFile(stopWordsDir string) (models.StopWordsFile, error) {
+	var file models.StopWordsFile
+	data, err := ioutil.ReadFile(stopWordsDir)
+	if err != nil {
+		return file, err
+	}
+
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '[' {
+		err = json.Unmarshal(trimmed, &file.Global)
+	} else {
+		err = json.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return file, fmt.Errorf("not a valid stop words file: %w", err)
+	}
+	return file, nil
+}
+
+func NewStopWordListsFromFile(file models.StopWordsFile) *StopWordLists {
+	global := make(map[string]struct{}, len(file.Global))
+	for _, word := range file.Global {
+		global[word] = struct{}{}
+	}
+	return NewStopWordLists(global, file.Classes)
+}
+
+// Returns stop words removed from texts to predict.
+func (l *StopWordLists) Global() map[string]struct{} {
+	return l.global
+}
+
+// Returns stop words removed from texts of the class during training.
+func (l *StopWordLists) ForClass(class string) map[string]struct{} {
+	if classWords, ok := l.classes[class]; ok {
+		return classWords
+	}
+	return l.defaultClass
+}
+
+// Counts occurrences of class stop words, which are not removed from texts to predict.
+func (l *StopWordLists) countClassStopWords(class string, texts []string) map[string]int {
+	extra, ok := l.extra[class]
+	if !ok {
+		extra = l.defaultExtra
+	}
+	if len(extra) == 0 {
+		return nil
+	}
+
+	counts := make(map[string]int)
+	for _, text := range texts {
+		for _, word := range words(text) {
+			if _, ok := extra[word]; ok {
+				counts[word]++
+			}
+		}
+	}
+	return counts
+}
+
+// Logs class stop words removed from texts of the class, the most frequent first.
+func logRemovedStopWords(class string, removed map[string]int) {
+	if len(removed) == 0 {
+		return
+	}
+
+	removedWords := make([]string, 0, len(removed))
+	for word := range removed {
+		removedWords = append(removedWords, word)
+	}
+	sort.Slice(removedWords, func(i, j int) bool {
+		if removed[removedWords[i]] != removed[removedWords[j]] {
+			return removed[removedWords[i]] > removed[removedWords[j]]
+		}
+		return removedWords[i] < removedWords[j]
+	})
+
+	counts := make([]string, len(removedWords))
+	for i, word := range removedWords {
+		counts[i] = fmt.Sprintf("%s (%d)", word, removed[word])
+	}
+	log.Printf("Removed class stop words from '%s': %s", class, strings.Join(counts, ", "))
+}
@@ -0,0 +1,160 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+
+	models "github.com/ivar-mahhonin/food-delivery-classifier/trainer-service/pkg/models"
+	"github.com/navossoc/bayesian"
+)
+
+func newTestStopWordLists() *StopWordLists {
+	global := map[string]struct{}{"the": {}, "my": {}, "account": {}}
+	return NewStopWordLists(global, []models.StopWords{
+		{Class: "Mortgage", Texts: []string{"Card"}},
+		{Class: "Bank account", Keep: []string{"account"}},
+	})
+}
+
+func TestStopWordListsForClass(t *testing.T) {
+	lists := newTestStopWordLists()
+
+	expected := map[string]struct{}{"the": {}, "my": {}, "account": {}, "card": {}}
+	if result := lists.ForClass("Mortgage"); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+
+	expected = map[string]struct{}{"the": {}, "my": {}}
+	if result := lists.ForClass("Bank account"); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+
+	expected = map[string]struct{}{"the": {}, "my": {}, "account": {}}
+	if result := lists.ForClass("Credit card"); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected global stop words for class without overrides: got %v, want %v", result, expected)
+	}
+}
+
+func TestStopWordListsGlobal(t *testing.T) {
+	expected := map[string]struct{}{"the": {}, "my": {}}
+	if result := newTestStopWordLists().Global(); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected stop words kept by a class to be learned from texts to predict: got %v, want %v", result, expected)
+	}
+}
+
+func TestStopWordListsLowercaseGlobalWords(t *testing.T) {
+	lists := NewStopWordLists(map[string]struct{}{"The": {}, "ACCOUNT": {}}, []models.StopWords{
+		{Class: "Bank account", Keep: []string{"Account"}},
+	})
+
+	expected := map[string]struct{}{"the": {}}
+	if result := lists.Global(); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+	expected = map[string]struct{}{"the": {}, "account": {}}
+	if result := lists.ForClass("Mortgage"); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected global stop words to be lowercased for classes without overrides: got %v, want %v", result, expected)
+	}
+	if tokens := newTestTokenizer(t, TokenizerConfig{}).Tokenize([]string{"The account"}, lists.ForClass("Mortgage")); len(tokens) != 0 {
+		t.Errorf("Expected mixed case global stop words to be removed, got %v", tokens)
+	}
+}
+
+func TestCountClassStopWords(t *testing.T) {
+	lists := newTestStopWordLists()
+
+	result := lists.countClassStopWords("Mortgage", []string{"My card and the account", "card escrow"})
+	expected := map[string]int{"card": 2, "account": 1}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+
+	result = lists.countClassStopWords("Credit card", []string{"my card account"})
+	expected = map[string]int{"account": 1}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected global stop words kept by other classes to be counted: got %v, want %v", result, expected)
+	}
+}
+
+func TestTrainClassifierWithClassStopWords(t *testing.T) {
+	cases := map[string][]string{
+		"Mortgage":     {"card payment for the escrow"},
+		"Bank account": {"my account is closed"},
+		"Credit card":  {"card fee on my account"},
+	}
+	classifier := trainTestClassifier(t, cases, newTestStopWordLists(), newTestTokenizer(t, TokenizerConfig{}))
+
+	learned := func(class string, word string) bool {
+		_, ok := classifier.WordsByClass(bayesian.Class(class))[word]
+		return ok
+	}
+	if learned("Mortgage", "card") {
+		t.Errorf("Expected Mortgage not to learn its class stop word")
+	}
+	if !learned("Credit card", "card") {
+		t.Errorf("Expected Credit card to learn a stop word of another class")
+	}
+	if !learned("Bank account", "account") {
+		t.Errorf("Expected Bank account to learn a kept global stop word")
+	}
+	if learned("Credit card", "account") {
+		t.Errorf("Expected Credit card not to learn a global stop word kept by another class")
+	}
+}
+
+func TestReadStopWordListsFlatArray(t *testing.T) {
+	err := ioutil.WriteFile("test.json", []byte(` ["a", "b"]`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test file: %v", err)
+	}
+	defer os.Remove("test.json")
+
+	lists, err := ReadStopWordLists("test.json")
+	if err != nil {
+		t.Fatalf("Error reading stop words file: %v", err)
+	}
+	expected := map[string]struct{}{"a": {}, "b": {}}
+	if !reflect.DeepEqual(lists.Global(), expected) || !reflect.DeepEqual(lists.ForClass("class1"), expected) {
+		t.Errorf("Test case failed: got %v, want %v", lists.Global(), expected)
+	}
+}
+
+func TestReadStopWordListsWithClasses(t *testing.T) {
+	err := ioutil.WriteFile("test.json", []byte(`{"global": ["a", "b"], "classes": [{"class": "class1", "texts": ["c"], "keep": ["b"]}]}`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test file: %v", err)
+	}
+	defer os.Remove("test.json")
+
+	lists, err := ReadStopWordLists("test.json")
+	if err != nil {
+		t.Fatalf("Error reading stop words file: %v", err)
+	}
+	expected := map[string]struct{}{"a": {}, "c": {}}
+	if result := lists.ForClass("class1"); !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+
+	stopWords, err := ReadStopWords("test.json")
+	if err != nil {
+		t.Fatalf("Error reading stop words file: %v", err)
+	}
+	expected = map[string]struct{}{"a": {}}
+	if !reflect.DeepEqual(stopWords, expected) {
+		t.Errorf("Test case failed: got %v, want %v", stopWords, expected)
+	}
+}
+
+func TestReadStopWordListsInvalidJSON(t *testing.T) {
+	err := ioutil.WriteFile("test.json", []byte(`{"global": [`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test file: %v", err)
+	}
+	defer os.Remove("test.json")
+
+	if _, err := ReadStopWordLists("test.json"); err == nil {
+		t.Errorf("Expected error reading invalid JSON")
+	}
+}
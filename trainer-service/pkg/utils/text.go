@@ -5,31 +5,9 @@ import (
 	"unicode"
 )
 
-//Tokenize and clean text
-func Tokenize(texts []string, stopWords map[string]struct{}) []string {
-	result := make([]string, 0)
-
-	for _, t := range texts {
-		text := strings.ToLower(t)
-		tokens := strings.FieldsFunc(text, func(r rune) bool { return !unicode.IsLetter(r) })
-		withoutStopWords := cleanTokenizedText(tokens, stopWords)
-		result = append(result, withoutStopWords...)
-	}
-
-	return removeDuplicates(result)
-}
-
-//Removes stop words from string
-func cleanTokenizedText(tokens []string, stopWords map[string]struct{}) []string {
-	cleaned := []string{}
-
-	for _, word := range tokens {
-		if _, ok := stopWords[strings.ToLower(word)]; !ok {
-			lemmatized := Lemmatize(word)
-			cleaned = append(cleaned, lemmatized)
-		}
-	}
-	return cleaned
+// Splits lower cased text into words of letters
+func words(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) })
 }
 
 //Remove duplicates from the strings
@@ -8,7 +8,7 @@ import (
 func TestTokenize(t *testing.T) {
 	stopWords := map[string]struct{}{"the": {}, "is": {}}
 	texts := []string{"The cat is on the mat", "The dog is in the garden"}
-	result := Tokenize(texts, stopWords)
+	result := newTestTokenizer(t, TokenizerConfig{}).Tokenize(texts, stopWords)
 	expected := []string{"cat", "on", "mat", "dog", "in", "garden"}
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("Test case failed: got %v, want %v", result, expected)
@@ -18,17 +18,17 @@ func TestTokenize(t *testing.T) {
 func TestTokenizeEmptyStopWords(t *testing.T) {
 	stopWords := map[string]struct{}{}
 	texts := []string{"The cat is on the mat", "The dog is in the garden"}
-	result := Tokenize(texts, stopWords)
+	result := newTestTokenizer(t, TokenizerConfig{}).Tokenize(texts, stopWords)
 	expected := []string{"the", "cat", "be", "on", "mat", "dog", "in", "garden"}
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("Test case failed: got %v, want %v", result, expected)
 	}
 }
 
-func TestCleanTokenizedText(t *testing.T) {
+func TestStopWordsStage(t *testing.T) {
 	stopWords := map[string]struct{}{"the": {}, "is": {}}
 	tokens := []string{"The", "cat", "is", "on", "the", "mat"}
-	result := cleanTokenizedText(tokens, stopWords)
+	result := stopWordsStage(tokens, stopWords)
 	expected := []string{"cat", "on", "mat"}
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("Test case failed: got %v, want %v", result, expected)
@@ -38,53 +38,62 @@ func TestCleanTokenizedText(t *testing.T) {
 func TestTokenizeAllStopWords(t *testing.T) {
 	stopWords := map[string]struct{}{"The": {}, "cat": {}, "is": {}, "on": {}, "the": {}, "mat": {}, "dog": {}, "in": {}, "garden": {}}
 	texts := []string{"The cat is on the mat", "The dog is in the garden"}
-	result := Tokenize(texts, stopWords)
+	result := newTestTokenizer(t, TokenizerConfig{}).Tokenize(texts, stopWords)
 	expected := []string{}
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("Test case failed: got %v, want %v", result, expected)
 	}
 }
 
-func TestCleanTokenizedTextEmptyStopWords(t *testing.T) {
+func TestStopWordsStageEmptyStopWords(t *testing.T) {
 	stopWords := map[string]struct{}{}
 	tokens := []string{"The", "cat", "is", "on", "the", "mat"}
-	result := cleanTokenizedText(tokens, stopWords)
-	expected := []string{"The", "cat", "be", "on", "the", "mat"}
+	result := stopWordsStage(tokens, stopWords)
+	expected := []string{"The", "cat", "is", "on", "the", "mat"}
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("Test case failed: got %v, want %v", result, expected)
 	}
 }
 
-func TestCleanTokenizedTextAllStopWords(t *testing.T) {
+func TestStopWordsStageAllStopWords(t *testing.T) {
 	stopWords := map[string]struct{}{"The": {}, "cat": {}, "is": {}, "on": {}, "the": {}, "mat": {}}
 	tokens := []string{"The", "cat", "is", "on", "the", "mat"}
-	result := cleanTokenizedText(tokens, stopWords)
+	result := stopWordsStage(tokens, stopWords)
 	expected := []string{}
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("Test case failed: got %v, want %v", result, expected)
 	}
 }
 
-func TestCleanTokenizedTextSomeStopWords(t *testing.T) {
+func TestStopWordsStageSomeStopWords(t *testing.T) {
 	stopWords := map[string]struct{}{"The": {}, "is": {}, "the": {}}
 	tokens := []string{"The", "cat", "is", "on", "the", "mat"}
-	result := cleanTokenizedText(tokens, stopWords)
+	result := stopWordsStage(tokens, stopWords)
 	expected := []string{"cat", "on", "mat"}
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("Test case failed: got %v, want %v", result, expected)
 	}
 }
 
-func TestCleanTokenizedTextEmptyTokens(t *testing.T) {
+func TestStopWordsStageEmptyTokens(t *testing.T) {
 	stopWords := map[string]struct{}{"The": {}, "is": {}, "the": {}}
 	tokens := []string{}
-	result := cleanTokenizedText(tokens, stopWords)
+	result := stopWordsStage(tokens, stopWords)
 	expected := []string{}
 	if !reflect.DeepEqual(result, expected) {
 		t.Errorf("Test case failed: got %v, want %v", result, expected)
 	}
 }
 
+func TestLemmatizeStage(t *testing.T) {
+	tokens := []string{"The", "cat", "is", "on", "the", "mat"}
+	result := LemmatizeStage(newTestLemmatizer(t)).Process(tokens, nil)
+	expected := []string{"The", "cat", "be", "on", "the", "mat"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+}
+
 func TestRemoveDuplicates(t *testing.T) {
 	result := removeDuplicates([]string{"a", "b", "c", "a", "d", "b"})
 	expected := []string{"a", "b", "c", "d"}
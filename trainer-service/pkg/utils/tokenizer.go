@@ -0,0 +1,199 @@
+package util
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"strings"
+)
+
+const (
+	MAX_NGRAMS = 3
+
+	// Every token is counted once per class, however often it is repeated.
+	TERM_FREQUENCY_BINARY = "binary"
+	// Every occurrence of a token is counted.
+	TERM_FREQUENCY_RAW = "raw"
+	// A token repeated n times in a text is counted 1 + ln(n) times, rounded.
+	TERM_FREQUENCY_LOG = "log"
+	// A token is counted at most MaxTermFrequency times per text.
+	TERM_FREQUENCY_CAP = "cap"
+)
+
+// Options of text tokenization. The config is stored with the model, so that
+// texts to predict are tokenized the same way as the training data.
+// The zero value tokenizes texts into single words.
+type TokenizerConfig struct {
+	// Longest sequence of words joined into a single token, 0 and 1 keep single words only.
+	NGrams int `json:"ngrams"`
+	// How repeated tokens are counted, TERM_FREQUENCY_BINARY when empty.
+	TermFrequency string `json:"term_frequency,omitempty"`
+	// Maximum count of a token per text with TERM_FREQUENCY_CAP.
+	MaxTermFrequency int `json:"max_term_frequency,omitempty"`
+	// Replace amounts, dates, masked values and personal data with placeholders before tokenizing.
+	Normalize bool `json:"normalize,omitempty"`
+	// Stage reducing words to their base form, STAGE_LEMMATIZE or STAGE_STEM. STAGE_LEMMATIZE when empty.
+	Morphology string `json:"morphology,omitempty"`
+	// Text processing stages. When empty, the default pipeline with NGrams and Normalize is used.
+	Stages []StageConfig `json:"stages,omitempty"`
+}
+
+// Checks the config and builds its pipeline, failing on unknown stages or options out of range.
+func (c TokenizerConfig) Validate() error {
+	_, err := NewTokenizer(c)
+	return err
+}
+
+func (c TokenizerConfig) validateOptions() error {
+	if c.NGrams < 0 || c.NGrams > MAX_NGRAMS {
+		return fmt.Errorf("ngrams must be between 0 and %d, 0 keeps single words only, got %d", MAX_NGRAMS, c.NGrams)
+	}
+	if len(c.Stages) > 0 && (c.NGrams > 1 || c.Normalize || c.Morphology != "") {
+		return errors.New("ngrams, normalize and morphology must be declared as stages when stages are set")
+	}
+	switch c.Morphology {
+	case "", STAGE_LEMMATIZE, STAGE_STEM:
+	default:
+		return fmt.Errorf("morphology must be %s or %s, got %q", STAGE_LEMMATIZE, STAGE_STEM, c.Morphology)
+	}
+
+	switch c.TermFrequency {
+	case "", TERM_FREQUENCY_BINARY, TERM_FREQUENCY_RAW, TERM_FREQUENCY_LOG:
+	case TERM_FREQUENCY_CAP:
+		if c.MaxTermFrequency < 1 {
+			return fmt.Errorf("max_term_frequency must be at least 1 with %s term frequency, got %d", TERM_FREQUENCY_CAP, c.MaxTermFrequency)
+		}
+	default:
+		return fmt.Errorf("unknown term frequency %q", c.TermFrequency)
+	}
+	return nil
+}
+
+// Returns declared stages, or the default ones: normalization when enabled, splitting into
+// words, lower casing, stop words removal, lemmatization or stemming and n-grams when enabled.
+func (c TokenizerConfig) PipelineStages() []StageConfig {
+	if len(c.Stages) > 0 {
+		return c.Stages
+	}
+
+	stages := []StageConfig{}
+	if c.Normalize {
+		stages = append(stages, StageConfig{Type: STAGE_NORMALIZE})
+	}
+	stages = append(stages,
+		StageConfig{Type: STAGE_SPLIT},
+		StageConfig{Type: STAGE_LOWERCASE},
+		StageConfig{Type: STAGE_STOP_WORDS},
+		StageConfig{Type: c.morphology()},
+	)
+	if c.NGrams > 1 {
+		stages = append(stages, StageConfig{Type: STAGE_NGRAMS, N: c.NGrams})
+	}
+	return stages
+}
+
+func (c TokenizerConfig) Pipeline() (*Pipeline, error) {
+	return NewPipeline(c.PipelineStages())
+}
+
+func (c TokenizerConfig) morphology() string {
+	if c.Morphology == "" {
+		return STAGE_LEMMATIZE
+	}
+	return c.Morphology
+}
+
+func (c TokenizerConfig) binary() bool {
+	return c.TermFrequency == "" || c.TermFrequency == TERM_FREQUENCY_BINARY
+}
+
+// Tokenizes texts with the pipeline built once from a valid config. A Tokenizer is safe
+// for concurrent use, so a single one is shared by all training workers and requests.
+type Tokenizer struct {
+	config   TokenizerConfig
+	pipeline *Pipeline
+}
+
+// Validates the config and builds its pipeline.
+func NewTokenizer(config TokenizerConfig) (*Tokenizer, error) {
+	if err := config.validateOptions(); err != nil {
+		return nil, err
+	}
+	pipeline, err := config.Pipeline()
+	if err != nil {
+		return nil, err
+	}
+	return &Tokenizer{config: config, pipeline: pipeline}, nil
+}
+
+// Returns the config the tokenizer was built from.
+func (t *Tokenizer) Config() TokenizerConfig {
+	return t.config
+}
+
+// Tokenizes texts with the pipeline. With binary term frequency tokens are unique,
+// otherwise a token is repeated as many times as it is counted.
+func (t *Tokenizer) Tokenize(texts []string, stopWords map[string]struct{}) []string {
+	result := make([]string, 0)
+	for _, text := range texts {
+		result = append(result, t.config.weigh(t.pipeline.Process(text, stopWords))...)
+	}
+
+	if t.config.binary() {
+		return removeDuplicates(result)
+	}
+	return result
+}
+
+// Repeats tokens of a single text according to the term frequency.
+func (c TokenizerConfig) weigh(tokens []string) []string {
+	if c.TermFrequency != TERM_FREQUENCY_LOG && c.TermFrequency != TERM_FREQUENCY_CAP {
+		return tokens
+	}
+
+	counts := make(map[string]int)
+	for _, token := range tokens {
+		counts[token]++
+	}
+
+	weighted := make([]string, 0, len(tokens))
+	for _, token := range removeDuplicates(tokens) {
+		n := counts[token]
+		if c.TermFrequency == TERM_FREQUENCY_LOG {
+			n = int(math.Round(1 + math.Log(float64(n))))
+		} else if n > c.MaxTermFrequency {
+			n = c.MaxTermFrequency
+		}
+		for i := 0; i < n; i++ {
+			weighted = append(weighted, token)
+		}
+	}
+	return weighted
+}
+
+// Joins sequences of 2 up to n consecutive words with spaces.
+func nGrams(tokens []string, n int) []string {
+	var result []string
+	for size := 2; size <= n; size++ {
+		for i := 0; i+size <= len(tokens); i++ {
+			result = append(result, strings.Join(tokens[i:i+size], " "))
+		}
+	}
+	return result
+}
+
+// Reads tokenizer config from a JSON file.
+func ReadTokenizerConfig(configFileDir string) (TokenizerConfig, error) {
+	var config TokenizerConfig
+
+	bytes, err := ioutil.ReadFile(configFileDir)
+	if err != nil {
+		return config, err
+	}
+	if err := json.Unmarshal(bytes, &config); err != nil {
+		return config, fmt.Errorf("not a valid tokenizer config: %w", err)
+	}
+	return config, config.Validate()
+}
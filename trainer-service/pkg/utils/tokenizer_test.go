@@ -0,0 +1,124 @@
+package util
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func newTestTokenizer(t *testing.T, config TokenizerConfig) *Tokenizer {
+	tokenizer, err := NewTokenizer(config)
+	if err != nil {
+		t.Fatalf("Error creating tokenizer: %v", err)
+	}
+	return tokenizer
+}
+
+func TestTokenizeNGrams(t *testing.T) {
+	stopWords := map[string]struct{}{"a": {}, "the": {}}
+	result := newTestTokenizer(t, TokenizerConfig{NGrams: 3}).Tokenize([]string{"A late fee on the credit report", "late fee"}, stopWords)
+	expected := []string{"late", "fee", "on", "credit", "report", "late fee", "fee on", "on credit", "credit report", "late fee on", "fee on credit", "on credit report"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+}
+
+func TestTokenizeNGramsDoNotCrossTexts(t *testing.T) {
+	result := newTestTokenizer(t, TokenizerConfig{NGrams: 2}).Tokenize([]string{"late", "fee"}, map[string]struct{}{})
+	expected := []string{"late", "fee"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Test case failed: got %v, want %v", result, expected)
+	}
+}
+
+func TestTokenizeZeroConfig(t *testing.T) {
+	texts := []string{"The cats are on the mat"}
+	stopWords := map[string]struct{}{"the": {}}
+	result := newTestTokenizer(t, TokenizerConfig{}).Tokenize(texts, stopWords)
+	expected := []string{"cat", "be", "on", "mat"}
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected zero config to tokenize single words: got %v, want %v", result, expected)
+	}
+}
+
+func TestNewTokenizerInvalidConfig(t *testing.T) {
+	if _, err := NewTokenizer(TokenizerConfig{Stages: []StageConfig{{Type: "unknown"}}}); err == nil {
+		t.Errorf("Expected error creating tokenizer with unknown stage")
+	}
+	if _, err := NewTokenizer(TokenizerConfig{TermFrequency: TERM_FREQUENCY_CAP}); err == nil {
+		t.Errorf("Expected error creating tokenizer without max term frequency")
+	}
+}
+
+func TestTokenizerConfigValidate(t *testing.T) {
+	for _, ngrams := range []int{0, 1, MAX_NGRAMS} {
+		if err := (TokenizerConfig{NGrams: ngrams}).Validate(); err != nil {
+			t.Errorf("Unexpected error for %d ngrams: %v", ngrams, err)
+		}
+	}
+	for _, ngrams := range []int{-1, MAX_NGRAMS + 1} {
+		if err := (TokenizerConfig{NGrams: ngrams}).Validate(); err == nil {
+			t.Errorf("Expected error for %d ngrams", ngrams)
+		}
+	}
+}
+
+func TestReadTokenizerConfig(t *testing.T) {
+	err := ioutil.WriteFile("test.json", []byte(`{"ngrams": 2}`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test file: %v", err)
+	}
+	defer os.Remove("test.json")
+
+	result, err := ReadTokenizerConfig("test.json")
+	if err != nil {
+		t.Fatalf("Error reading tokenizer config: %v", err)
+	}
+	if result.NGrams != 2 {
+		t.Errorf("Test case failed: got %+v, want 2 ngrams", result)
+	}
+}
+
+func TestReadTokenizerConfigInvalid(t *testing.T) {
+	err := ioutil.WriteFile("test.json", []byte(`{"ngrams": 5}`), 0666)
+	if err != nil {
+		t.Errorf("Error creating test file: %v", err)
+	}
+	defer os.Remove("test.json")
+
+	if _, err := ReadTokenizerConfig("test.json"); err == nil {
+		t.Errorf("Expected error reading config with too long n-grams")
+	}
+}
+
+func TestTokenizeTermFrequency(t *testing.T) {
+	texts := []string{"fee fee fee fee card", "fee"}
+	tests := []struct {
+		config   TokenizerConfig
+		expected []string
+	}{
+		{TokenizerConfig{}, []string{"fee", "card"}},
+		{TokenizerConfig{TermFrequency: TERM_FREQUENCY_RAW}, []string{"fee", "fee", "fee", "fee", "card", "fee"}},
+		{TokenizerConfig{TermFrequency: TERM_FREQUENCY_LOG}, []string{"fee", "fee", "card", "fee"}},
+		{TokenizerConfig{TermFrequency: TERM_FREQUENCY_CAP, MaxTermFrequency: 3}, []string{"fee", "fee", "fee", "card", "fee"}},
+	}
+	for _, test := range tests {
+		result := newTestTokenizer(t, test.config).Tokenize(texts, map[string]struct{}{})
+		if !reflect.DeepEqual(result, test.expected) {
+			t.Errorf("Test case %q failed: got %v, want %v", test.config.TermFrequency, result, test.expected)
+		}
+	}
+}
+
+func TestTokenizerConfigValidateTermFrequency(t *testing.T) {
+	if err := (TokenizerConfig{TermFrequency: "squared"}).Validate(); err == nil {
+		t.Errorf("Expected error for unknown term frequency")
+	}
+	if err := (TokenizerConfig{TermFrequency: TERM_FREQUENCY_CAP}).Validate(); err == nil {
+		t.Errorf("Expected error for cap term frequency without maximum")
+	}
+	if err := (TokenizerConfig{TermFrequency: TERM_FREQUENCY_CAP, MaxTermFrequency: 1}).Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}